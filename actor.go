@@ -1,6 +1,10 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
 	"sync"
 )
 
@@ -9,12 +13,52 @@ type ActorMessage interface {
 	ActorMessage()
 }
 
+// ErrActorStopped is returned by Ask when the actor is not running
+var ErrActorStopped = errors.New("actor stopped")
+
+// AskMsg wraps a message sent with Ask. The handler must answer it with
+// Reply or Fail exactly once.
+type AskMsg struct {
+	Msg   ActorMessage
+	reply chan askReply
+}
+
+func (m AskMsg) ActorMessage() {}
+
+type askReply struct {
+	value interface{}
+	err   error
+}
+
+// Reply answers the asker. Never blocks, even if the asker gave up; only
+// the first answer counts.
+func (m AskMsg) Reply(value interface{}) {
+	m.answer(askReply{value: value})
+}
+
+// Fail answers the asker with an error. Like Reply it never blocks, so a
+// handler that replied and then panicked can still be supervised.
+func (m AskMsg) Fail(err error) {
+	m.answer(askReply{err: err})
+}
+
+func (m AskMsg) answer(r askReply) {
+	select {
+	case m.reply <- r:
+	default:
+	}
+}
+
 // Actor represents a concurrent entity that processes messages
 type Actor struct {
-	inbox   chan ActorMessage
-	handler func(ActorMessage)
-	wg      *sync.WaitGroup
-	stop    chan struct{}
+	inbox      chan ActorMessage
+	handler    func(ActorMessage)
+	wg         *sync.WaitGroup
+	stop       chan struct{}
+	stopOnce   sync.Once
+	supervisor *Supervisor
+	mailbox    MailboxConfig
+	counters   mailboxCounters
 }
 
 // NewActor creates a new actor with a message handler
@@ -27,6 +71,18 @@ func NewActor(handler func(ActorMessage), bufferSize int) *Actor {
 	}
 }
 
+// SetMailbox configures the inbox's overflow policy and dead letter sink.
+// Must be called before Start.
+func (a *Actor) SetMailbox(config MailboxConfig) {
+	a.mailbox = config
+}
+
+// Supervise recovers panics in the handler using s. Must be called before
+// Start. Unsupervised actors crash the process on a panic.
+func (a *Actor) Supervise(s *Supervisor) {
+	a.supervisor = s
+}
+
 // Start begins processing messages
 func (a *Actor) Start() {
 	a.wg.Add(1)
@@ -35,7 +91,10 @@ func (a *Actor) Start() {
 		for {
 			select {
 			case msg := <-a.inbox:
-				a.handler(msg)
+				if !a.handle(msg) {
+					a.halt()
+					return
+				}
 			case <-a.stop:
 				return
 			}
@@ -43,28 +102,175 @@ func (a *Actor) Start() {
 	}()
 }
 
-// Send sends a message to the actor
-func (a *Actor) Send(msg ActorMessage) {
+// handle runs the handler for one message. Returns false if the supervisor
+// decided the actor should stop.
+func (a *Actor) handle(msg ActorMessage) (ok bool) {
+	if a.supervisor == nil {
+		a.handler(msg)
+		return true
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if ask, isAsk := msg.(AskMsg); isAsk {
+				ask.Fail(fmt.Errorf("actor panicked: %v", r))
+			}
+			ok = a.supervisor.recovered(msg, r, debug.Stack())
+		}
+	}()
+	a.handler(msg)
+	return true
+}
+
+// Send sends a message to the actor, applying the mailbox policy if the
+// inbox is full. Returns ErrActorStopped if the actor is not running and
+// ErrMailboxFull if the policy rejected the message. Dropped and rejected
+// messages go to the dead letter sink.
+func (a *Actor) Send(msg ActorMessage) error {
+	select {
+	case <-a.stop:
+		a.drop(msg, ReasonActorStopped)
+		return ErrActorStopped
+	default:
+	}
+
+	// Fast path: there's room
 	select {
 	case a.inbox <- msg:
+		a.counters.delivered.Add(1)
+		return nil
+	default:
+	}
+
+	switch a.mailbox.Policy {
+	case MailboxDropNewest:
+		a.drop(msg, ReasonMailboxFull)
+		return nil
+
+	case MailboxReject:
+		a.counters.rejected.Add(1)
+		a.recordDeadLetter(msg, ReasonMailboxFull)
+		return ErrMailboxFull
+
+	case MailboxDropOldest:
+		for {
+			select {
+			case a.inbox <- msg:
+				a.counters.delivered.Add(1)
+				return nil
+			case <-a.stop:
+				a.drop(msg, ReasonActorStopped)
+				return ErrActorStopped
+			default:
+			}
+			select {
+			case old := <-a.inbox:
+				// An evicted Ask is answered, or its asker waits out its
+				// whole timeout for a reply that never comes
+				if ask, isAsk := old.(AskMsg); isAsk {
+					ask.Fail(ErrMailboxFull)
+				}
+				a.drop(old, ReasonEvicted)
+			default:
+			}
+		}
+	}
+
+	a.counters.blocked.Add(1)
+	select {
+	case a.inbox <- msg:
+		a.counters.delivered.Add(1)
+		return nil
 	case <-a.stop:
-		// Actor stopped, drop message
+		a.drop(msg, ReasonActorStopped)
+		return ErrActorStopped
+	}
+}
+
+// drop counts a dropped message and hands it to the dead letter sink
+func (a *Actor) drop(msg ActorMessage, reason string) {
+	a.counters.dropped.Add(1)
+	a.recordDeadLetter(msg, reason)
+}
+
+func (a *Actor) recordDeadLetter(msg ActorMessage, reason string) {
+	if a.mailbox.DeadLetters != nil {
+		a.mailbox.DeadLetters.Record(a.mailbox.Name, msg, reason)
 	}
 }
 
-// Stop gracefully stops the actor
+// Stats returns a snapshot of the mailbox counters
+func (a *Actor) Stats() MailboxStats {
+	return MailboxStats{
+		Name:      a.mailbox.Name,
+		Policy:    a.mailbox.Policy.String(),
+		Queued:    len(a.inbox),
+		Capacity:  cap(a.inbox),
+		Delivered: a.counters.delivered.Load(),
+		Blocked:   a.counters.blocked.Load(),
+		Dropped:   a.counters.dropped.Load(),
+		Rejected:  a.counters.rejected.Load(),
+	}
+}
+
+// Ask sends msg wrapped in an AskMsg and waits for the handler's reply, the
+// actor stopping, or ctx being done, whichever comes first. Asks wait for
+// room in the inbox whatever the mailbox policy.
+func (a *Actor) Ask(ctx context.Context, msg ActorMessage) (interface{}, error) {
+	ask := AskMsg{Msg: msg, reply: make(chan askReply, 1)}
+
+	select {
+	case <-a.stop:
+		return nil, ErrActorStopped
+	default:
+	}
+
+	select {
+	case a.inbox <- ask:
+		a.counters.delivered.Add(1)
+	case <-a.stop:
+		return nil, ErrActorStopped
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case r := <-ask.reply:
+		return r.value, r.err
+	case <-a.stop:
+		return nil, ErrActorStopped
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Stop gracefully stops the actor. Safe to call after the supervisor has
+// already stopped it.
 func (a *Actor) Stop() {
-	close(a.stop)
+	a.halt()
 	a.wg.Wait()
 }
 
+func (a *Actor) halt() {
+	a.stopOnce.Do(func() { close(a.stop) })
+}
+
 // ActorRef is a reference to an actor for sending messages
 type ActorRef struct {
 	actor *Actor
 }
 
-func (ref *ActorRef) Tell(msg ActorMessage) {
-	if ref.actor != nil {
-		ref.actor.Send(msg)
+func (ref *ActorRef) Tell(msg ActorMessage) error {
+	if ref.actor == nil {
+		return ErrActorStopped
+	}
+	return ref.actor.Send(msg)
+}
+
+// Ask sends a request to the actor and waits for its reply
+func (ref *ActorRef) Ask(ctx context.Context, msg ActorMessage) (interface{}, error) {
+	if ref.actor == nil {
+		return nil, ErrActorStopped
 	}
+	return ref.actor.Ask(ctx, msg)
 }
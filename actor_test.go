@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
@@ -125,3 +126,215 @@ func TestActorConcurrency(t *testing.T) {
 		t.Errorf("Expected 100 messages processed, got %d", counter)
 	}
 }
+
+func TestSupervisedActorResumesAfterPanic(t *testing.T) {
+	received := make([]string, 0)
+	var mu sync.Mutex
+
+	handler := func(msg ActorMessage) {
+		if testMsg, ok := msg.(TestMsg); ok {
+			if testMsg.Value == "boom" {
+				panic("boom")
+			}
+			mu.Lock()
+			received = append(received, testMsg.Value)
+			mu.Unlock()
+		}
+	}
+
+	failures := make(chan Failure, 1)
+	supervisor := &Supervisor{
+		Strategy:  StrategyResume,
+		OnFailure: func(f Failure) { failures <- f },
+	}
+
+	actor := NewActor(handler, 10)
+	actor.Supervise(supervisor)
+	actor.Start()
+	defer actor.Stop()
+
+	actor.Send(TestMsg{Value: "before"})
+	actor.Send(TestMsg{Value: "boom"})
+	actor.Send(TestMsg{Value: "after"})
+
+	select {
+	case f := <-failures:
+		if f.Panic != "boom" || len(f.Stack) == 0 || f.Restarts != 1 {
+			t.Errorf("Unexpected failure report: %+v", f)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnFailure was not called")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 || received[1] != "after" {
+		t.Errorf("Expected the actor to keep going after the panic, got %v", received)
+	}
+	if supervisor.Restarts() != 1 {
+		t.Errorf("Expected 1 restart, got %d", supervisor.Restarts())
+	}
+}
+
+func TestSupervisorEscalatesToStop(t *testing.T) {
+	restarts := 0
+	processed := 0
+	var mu sync.Mutex
+
+	handler := func(msg ActorMessage) {
+		if msg.(TestMsg).Value == "boom" {
+			panic("boom")
+		}
+		mu.Lock()
+		processed++
+		mu.Unlock()
+	}
+
+	actor := NewActor(handler, 10)
+	actor.Supervise(&Supervisor{
+		Strategy:    StrategyRestart,
+		MaxRestarts: 2,
+		Window:      time.Minute,
+		Restart: func() {
+			mu.Lock()
+			restarts++
+			mu.Unlock()
+		},
+	})
+	actor.Start()
+	defer actor.Stop()
+
+	for i := 0; i < 3; i++ {
+		actor.Send(TestMsg{Value: "boom"})
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// The third failure stops the actor, so this is never handled
+	actor.Send(TestMsg{Value: "late"})
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if restarts != 2 {
+		t.Errorf("Expected 2 restarts before stopping, got %d", restarts)
+	}
+	if processed != 0 {
+		t.Errorf("Expected the stopped actor to ignore messages, processed %d", processed)
+	}
+}
+
+// AskTestMsg asks the test actor to echo a value back
+type AskTestMsg struct {
+	Value string
+	Delay time.Duration
+}
+
+func (m AskTestMsg) ActorMessage() {}
+
+func newEchoActor() *Actor {
+	return NewActor(func(msg ActorMessage) {
+		if ask, ok := msg.(AskMsg); ok {
+			req := ask.Msg.(AskTestMsg)
+			time.Sleep(req.Delay)
+			if req.Value == "boom" {
+				panic("boom")
+			}
+			ask.Reply("echo: " + req.Value)
+		}
+	}, 10)
+}
+
+func TestActorAsk(t *testing.T) {
+	actor := newEchoActor()
+	actor.Start()
+	defer actor.Stop()
+
+	reply, err := actor.Ask(context.Background(), AskTestMsg{Value: "hi"})
+	if err != nil {
+		t.Fatalf("Ask failed: %v", err)
+	}
+	if reply != "echo: hi" {
+		t.Errorf("Expected 'echo: hi', got %v", reply)
+	}
+}
+
+func TestActorAskTimeout(t *testing.T) {
+	actor := newEchoActor()
+	actor.Start()
+	defer actor.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := actor.Ask(ctx, AskTestMsg{Value: "slow", Delay: 100 * time.Millisecond})
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestActorAskStopped(t *testing.T) {
+	actor := newEchoActor()
+	actor.Start()
+	actor.Stop()
+
+	ref := &ActorRef{actor: actor}
+	if _, err := ref.Ask(context.Background(), AskTestMsg{Value: "hi"}); err != ErrActorStopped {
+		t.Errorf("Expected ErrActorStopped, got %v", err)
+	}
+}
+
+func TestActorAskPanicReturnsError(t *testing.T) {
+	actor := newEchoActor()
+	actor.Supervise(&Supervisor{Strategy: StrategyResume})
+	actor.Start()
+	defer actor.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := actor.Ask(ctx, AskTestMsg{Value: "boom"}); err == nil || err == context.DeadlineExceeded {
+		t.Errorf("Expected the panic to be reported, got %v", err)
+	}
+}
+
+func TestActorAskReplyThenPanicKeepsRunning(t *testing.T) {
+	actor := NewActor(func(msg ActorMessage) {
+		if ask, ok := msg.(AskMsg); ok {
+			ask.Reply("done")
+			if ask.Msg.(AskTestMsg).Value == "boom" {
+				panic("boom")
+			}
+		}
+	}, 10)
+	actor.Supervise(&Supervisor{Strategy: StrategyResume})
+	actor.Start()
+	defer actor.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// The reply wins; the panic's Fail must not block the actor
+	if reply, err := actor.Ask(ctx, AskTestMsg{Value: "boom"}); err != nil || reply != "done" {
+		t.Fatalf("Expected the first answer, got %v, %v", reply, err)
+	}
+	if _, err := actor.Ask(ctx, AskTestMsg{Value: "hi"}); err != nil {
+		t.Errorf("Expected the actor to keep answering, got %v", err)
+	}
+}
+
+func TestSupervisorWithoutWindowKeepsNoHistory(t *testing.T) {
+	s := &Supervisor{Strategy: StrategyResume, MaxRestarts: 3}
+	for i := 0; i < 3; i++ {
+		if !s.recovered(TestMsg{Value: "boom"}, "boom", nil) {
+			t.Fatalf("Expected failure %d to resume", i+1)
+		}
+	}
+	if s.recovered(TestMsg{Value: "boom"}, "boom", nil) {
+		t.Error("Expected the fourth failure to stop")
+	}
+	if len(s.recent) != 0 {
+		t.Errorf("Expected no restart times kept without a window, got %d", len(s.recent))
+	}
+}
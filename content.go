@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Content is the word lists games draw from
+type Content struct {
+	CharadeTopics    []string         `json:"charade_topics,omitempty" yaml:"charade_topics,omitempty"`
+	ClaudesGameWords []string         `json:"claudes_game_words,omitempty" yaml:"claudes_game_words,omitempty"`
+	ItemsToFind      []string         `json:"items_to_find,omitempty" yaml:"items_to_find,omitempty"`
+	PeopleToImitate  []string         `json:"people_to_imitate,omitempty" yaml:"people_to_imitate,omitempty"`
+	Adjectives       []string         `json:"adjectives,omitempty" yaml:"adjectives,omitempty"`
+	Nouns            []string         `json:"nouns,omitempty" yaml:"nouns,omitempty"`
+	FunnyVideos      []string         `json:"funny_videos,omitempty" yaml:"funny_videos,omitempty"` // YouTube video IDs
+	MadLibTemplates  []MadLibTemplate `json:"mad_lib_templates,omitempty" yaml:"mad_lib_templates,omitempty"`
+	TriviaQuestions  []TriviaQuestion `json:"trivia_questions,omitempty" yaml:"trivia_questions,omitempty"`
+}
+
+// builtinContent is the word lists compiled into the server. It's always
+// available as the "classic" pack, and fills in any list a room's packs
+// leave empty.
+var builtinContent = &Content{
+	CharadeTopics:    charadeTopics,
+	ClaudesGameWords: claudesGameWords,
+	ItemsToFind:      itemsToFind,
+	PeopleToImitate:  peopleToImitate,
+	Adjectives:       adjectives,
+	Nouns:            nouns,
+	FunnyVideos:      funnyVideos,
+	MadLibTemplates:  madLibTemplates,
+	TriviaQuestions:  triviaQuestions,
+}
+
+// BuiltinPack is the name of the pack of compiled-in content
+const BuiltinPack = "classic"
+
+// CustomPack is the pack that holds Mad Libs templates submitted through
+// the API. It's saved as customPackFile in the pack directory, so it
+// survives restarts and reloads like any other pack.
+const (
+	CustomPack         = "custom"
+	customPackFile     = "custom.json"
+	MaxCustomTemplates = 200
+)
+
+// Age ratings a pack can have
+const (
+	RatingEveryone = "everyone"
+	RatingTeen     = "teen"
+	RatingMature   = "mature"
+)
+
+// ContentPack is a named set of content loaded from a JSON or YAML file.
+// The word lists sit at the top level next to the pack's details.
+type ContentPack struct {
+	Name      string   `json:"name" yaml:"name"`
+	Language  string   `json:"language,omitempty" yaml:"language,omitempty"`     // defaults to "en"
+	AgeRating string   `json:"age_rating,omitempty" yaml:"age_rating,omitempty"` // everyone, teen or mature; defaults to everyone
+	Tags      []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Content   `yaml:",inline"`
+}
+
+// PackInfo describes a pack without its content
+type PackInfo struct {
+	Name      string   `json:"name"`
+	Language  string   `json:"language"`
+	AgeRating string   `json:"age_rating"`
+	Tags      []string `json:"tags,omitempty"`
+	Items     int      `json:"items"` // entries across all its lists
+}
+
+var (
+	packNamePattern  = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	youtubeIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+)
+
+// Validate checks a pack is usable, filling in defaults for the details it
+// leaves out
+func (p *ContentPack) Validate() error {
+	if !packNamePattern.MatchString(p.Name) {
+		return fmt.Errorf("pack name %q must be lowercase letters, digits and dashes", p.Name)
+	}
+	if p.Language == "" {
+		p.Language = "en"
+	}
+	switch p.AgeRating {
+	case "":
+		p.AgeRating = RatingEveryone
+	case RatingEveryone, RatingTeen, RatingMature:
+	default:
+		return fmt.Errorf("pack %s: unknown age rating %q", p.Name, p.AgeRating)
+	}
+	if p.size() == 0 {
+		return fmt.Errorf("pack %s has no content", p.Name)
+	}
+
+	for name, list := range map[string][]string{
+		"charade_topics":     p.CharadeTopics,
+		"claudes_game_words": p.ClaudesGameWords,
+		"items_to_find":      p.ItemsToFind,
+		"people_to_imitate":  p.PeopleToImitate,
+		"adjectives":         p.Adjectives,
+		"nouns":              p.Nouns,
+		"funny_videos":       p.FunnyVideos,
+	} {
+		for i, item := range list {
+			if strings.TrimSpace(item) == "" {
+				return fmt.Errorf("pack %s: %s entry %d is blank", p.Name, name, i+1)
+			}
+		}
+	}
+	// Claude's Game needs two different words
+	if len(p.ClaudesGameWords) == 1 {
+		return fmt.Errorf("pack %s: claudes_game_words needs at least two words", p.Name)
+	}
+	for _, id := range p.FunnyVideos {
+		if !youtubeIDPattern.MatchString(id) {
+			return fmt.Errorf("pack %s: %q is not a YouTube video ID", p.Name, id)
+		}
+	}
+	for i := range p.MadLibTemplates {
+		if err := p.MadLibTemplates[i].Normalize(); err != nil {
+			return fmt.Errorf("pack %s: mad_lib_templates entry %d: %w", p.Name, i+1, err)
+		}
+	}
+	for i, q := range p.TriviaQuestions {
+		if err := q.Validate(); err != nil {
+			return fmt.Errorf("pack %s: trivia_questions entry %d: %w", p.Name, i+1, err)
+		}
+	}
+	return nil
+}
+
+func (c *Content) size() int {
+	return len(c.CharadeTopics) + len(c.ClaudesGameWords) + len(c.ItemsToFind) +
+		len(c.PeopleToImitate) + len(c.Adjectives) + len(c.Nouns) +
+		len(c.FunnyVideos) + len(c.MadLibTemplates) + len(c.TriviaQuestions)
+}
+
+func (p *ContentPack) info() PackInfo {
+	return PackInfo{
+		Name:      p.Name,
+		Language:  p.Language,
+		AgeRating: p.AgeRating,
+		Tags:      p.Tags,
+		Items:     p.size(),
+	}
+}
+
+// readContentPack reads and validates a pack file. Unknown fields are
+// errors, so a misspelled list isn't silently ignored.
+func readContentPack(path string) (*ContentPack, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pack := &ContentPack{}
+	if filepath.Ext(path) == ".json" {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(pack)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(pack)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pack, pack.Validate()
+}
+
+// fileStamp is what a scan remembers about a pack file to spot changes
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// ContentLibrary is the content packs the server knows: the built-in pack
+// and every pack file in its directory. Rooms pick packs from it by name.
+type ContentLibrary struct {
+	dir string
+
+	mu    sync.RWMutex
+	packs map[string]*ContentPack
+	files map[string]fileStamp // pack files as of the last load
+
+	writeMu sync.Mutex // serializes changes to the custom pack file
+}
+
+// NewContentLibrary returns a library with just the built-in pack. Call
+// Reload to load dir's packs.
+func NewContentLibrary(dir string) *ContentLibrary {
+	return &ContentLibrary{
+		dir:   dir,
+		packs: map[string]*ContentPack{BuiltinPack: builtinPack()},
+	}
+}
+
+// LoadContentLibrary loads every pack in dir, failing if any is invalid. A
+// missing dir just means there are no extra packs.
+func LoadContentLibrary(dir string) (*ContentLibrary, error) {
+	l := NewContentLibrary(dir)
+	return l, l.Reload()
+}
+
+func builtinPack() *ContentPack {
+	return &ContentPack{
+		Name:      BuiltinPack,
+		Language:  "en",
+		AgeRating: RatingEveryone,
+		Content:   *builtinContent,
+	}
+}
+
+// Reload rereads the pack directory. If any pack is invalid the library
+// keeps the packs it had and returns the error.
+func (l *ContentLibrary) Reload() error {
+	files, err := l.scan()
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.files = files
+	l.mu.Unlock()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	packs := map[string]*ContentPack{BuiltinPack: builtinPack()}
+	for _, name := range names {
+		pack, err := readContentPack(filepath.Join(l.dir, name))
+		if err != nil {
+			return fmt.Errorf("content pack %s: %w", name, err)
+		}
+		if _, exists := packs[pack.Name]; exists {
+			return fmt.Errorf("content pack %s: a pack named %q is already loaded", name, pack.Name)
+		}
+		packs[pack.Name] = pack
+	}
+
+	l.mu.Lock()
+	l.packs = packs
+	l.mu.Unlock()
+	return nil
+}
+
+// scan lists the pack files in the directory
+func (l *ContentLibrary) scan() (map[string]fileStamp, error) {
+	files := make(map[string]fileStamp)
+	if l.dir == "" {
+		return files, nil
+	}
+	entries, err := os.ReadDir(l.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading content packs: %w", err)
+	}
+
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".json", ".yaml", ".yml":
+		default:
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.IsDir() {
+			continue
+		}
+		files[entry.Name()] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return files, nil
+}
+
+// changed reports whether pack files were added, removed or edited since
+// the last load
+func (l *ContentLibrary) changed() bool {
+	files, err := l.scan()
+	if err != nil {
+		return false
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if len(files) != len(l.files) {
+		return true
+	}
+	for name, stamp := range files {
+		if old, exists := l.files[name]; !exists || old != stamp {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch reloads the library whenever its pack files change, checking every
+// interval until stop is closed. Games already running keep their content.
+func (l *ContentLibrary) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !l.changed() {
+				continue
+			}
+			if err := l.Reload(); err != nil {
+				log.Printf("Keeping previous content packs: %v", err)
+			} else {
+				log.Printf("Reloaded content packs from %s", l.dir)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Packs describes every pack, sorted by name
+func (l *ContentLibrary) Packs() []PackInfo {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	infos := make([]PackInfo, 0, len(l.packs))
+	for _, pack := range l.packs {
+		infos = append(infos, pack.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Has reports whether a pack is loaded
+func (l *ContentLibrary) Has(name string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, exists := l.packs[name]
+	return exists
+}
+
+// AddTemplate validates a Mad Libs template and saves it to the custom
+// pack, which is rated for everyone, then reloads the library. The
+// template's prompts are filled in from its placeholders if left out.
+func (l *ContentLibrary) AddTemplate(t MadLibTemplate) (MadLibTemplate, error) {
+	if err := t.Normalize(); err != nil {
+		return t, err
+	}
+	if l.dir == "" {
+		return t, errors.New("no content directory to save templates in")
+	}
+
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+
+	path := filepath.Join(l.dir, customPackFile)
+	pack, err := readContentPack(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		pack = &ContentPack{
+			Name:      CustomPack,
+			Language:  "en",
+			AgeRating: RatingEveryone,
+			Tags:      []string{"user-submitted"},
+		}
+	case err != nil:
+		return t, fmt.Errorf("content pack %s: %w", customPackFile, err)
+	}
+	if len(pack.MadLibTemplates) >= MaxCustomTemplates {
+		return t, fmt.Errorf("the %s pack already has %d templates", CustomPack, MaxCustomTemplates)
+	}
+	pack.MadLibTemplates = append(pack.MadLibTemplates, t)
+
+	data, err := json.MarshalIndent(pack, "", "  ")
+	if err != nil {
+		return t, err
+	}
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return t, err
+	}
+	// Write then rename, so a reload never reads half a file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return t, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return t, err
+	}
+	return t, l.Reload()
+}
+
+// Content merges the named packs' content. With no names it uses every
+// pack rated for everyone. Packs that no longer exist are skipped, and any
+// list the packs leave empty comes from the built-in content, so games
+// always have something to pick from.
+func (l *ContentLibrary) Content(names []string) *Content {
+	l.mu.RLock()
+	var packs []*ContentPack
+	if len(names) == 0 {
+		for _, pack := range l.packs {
+			if pack.AgeRating == RatingEveryone {
+				packs = append(packs, pack)
+			}
+		}
+	} else {
+		for _, name := range names {
+			if pack, exists := l.packs[name]; exists {
+				packs = append(packs, pack)
+			}
+		}
+	}
+	l.mu.RUnlock()
+	sort.Slice(packs, func(i, j int) bool { return packs[i].Name < packs[j].Name })
+
+	merged := &Content{}
+	for _, pack := range packs {
+		merged.CharadeTopics = append(merged.CharadeTopics, pack.CharadeTopics...)
+		merged.ClaudesGameWords = append(merged.ClaudesGameWords, pack.ClaudesGameWords...)
+		merged.ItemsToFind = append(merged.ItemsToFind, pack.ItemsToFind...)
+		merged.PeopleToImitate = append(merged.PeopleToImitate, pack.PeopleToImitate...)
+		merged.Adjectives = append(merged.Adjectives, pack.Adjectives...)
+		merged.Nouns = append(merged.Nouns, pack.Nouns...)
+		merged.FunnyVideos = append(merged.FunnyVideos, pack.FunnyVideos...)
+		merged.MadLibTemplates = append(merged.MadLibTemplates, pack.MadLibTemplates...)
+		merged.TriviaQuestions = append(merged.TriviaQuestions, pack.TriviaQuestions...)
+	}
+	merged.fillFrom(builtinContent)
+	return merged
+}
+
+// fillFrom copies in any list c doesn't have. Claude's Game needs two
+// words, so a single one counts as missing.
+func (c *Content) fillFrom(other *Content) {
+	fill := func(list *[]string, from []string) {
+		if len(*list) == 0 {
+			*list = from
+		}
+	}
+	fill(&c.CharadeTopics, other.CharadeTopics)
+	if len(c.ClaudesGameWords) < 2 {
+		c.ClaudesGameWords = other.ClaudesGameWords
+	}
+	fill(&c.ItemsToFind, other.ItemsToFind)
+	fill(&c.PeopleToImitate, other.PeopleToImitate)
+	fill(&c.Adjectives, other.Adjectives)
+	fill(&c.Nouns, other.Nouns)
+	fill(&c.FunnyVideos, other.FunnyVideos)
+	if len(c.MadLibTemplates) == 0 {
+		c.MadLibTemplates = other.MadLibTemplates
+	}
+	if len(c.TriviaQuestions) == 0 {
+		c.TriviaQuestions = other.TriviaQuestions
+	}
+}
+
+// contentPacks is the server's content library. It starts with just the
+// built-in pack; main loads the pack directory into it.
+var contentPacks = NewContentLibrary("")
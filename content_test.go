@@ -0,0 +1,166 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writePack(t *testing.T, dir, name, data string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+		t.Fatalf("Writing %s: %v", name, err)
+	}
+}
+
+func TestContentLibraryLoadsPacks(t *testing.T) {
+	dir := t.TempDir()
+	writePack(t, dir, "movies.yaml", "name: movies\ntags: [film]\ncharade_topics: [Jaws, Alien]\n")
+	writePack(t, dir, "spicy.json", `{"name": "spicy", "age_rating": "mature", "nouns": ["hot sauce"]}`)
+	writePack(t, dir, "notes.txt", "not a pack")
+
+	lib, err := LoadContentLibrary(dir)
+	if err != nil {
+		t.Fatalf("LoadContentLibrary failed: %v", err)
+	}
+	packs := lib.Packs()
+	if len(packs) != 3 || packs[0].Name != BuiltinPack || packs[1].Name != "movies" || packs[1].Language != "en" || packs[1].Items != 2 {
+		t.Fatalf("Expected classic, movies and spicy, got %+v", packs)
+	}
+
+	// No picks means every pack rated for everyone
+	content := lib.Content(nil)
+	if !containsString(content.CharadeTopics, "Jaws") || !containsString(content.CharadeTopics, "Titanic") {
+		t.Errorf("Expected classic and movies topics, got %v", content.CharadeTopics)
+	}
+	if containsString(content.Nouns, "hot sauce") {
+		t.Error("Expected mature packs to need picking")
+	}
+
+	// Lists the picked packs leave empty come from the built-in content
+	content = lib.Content([]string{"movies", "spicy", "gone"})
+	if len(content.CharadeTopics) != 2 || !reflect.DeepEqual(content.Nouns, []string{"hot sauce"}) {
+		t.Errorf("Expected only the picked packs' lists, got %v and %v", content.CharadeTopics, content.Nouns)
+	}
+	if len(content.ItemsToFind) != len(itemsToFind) {
+		t.Errorf("Expected missing lists to fall back to the built-in ones, got %v", content.ItemsToFind)
+	}
+
+	if lib, err := LoadContentLibrary(filepath.Join(dir, "missing")); err != nil || len(lib.Packs()) != 1 {
+		t.Errorf("Expected a missing directory to leave just the built-in pack, got %v", err)
+	}
+}
+
+func TestContentPackValidation(t *testing.T) {
+	bad := map[string]string{
+		"unknown field":  "name: typo\ncharade_topic: [Jaws]\n",
+		"no name":        "charade_topics: [Jaws]\n",
+		"empty":          "name: empty\n",
+		"blank entry":    "name: blank\nnouns: [hat, '  ']\n",
+		"bad rating":     "name: rated\nage_rating: R\nnouns: [hat]\n",
+		"bad video":      "name: videos\nfunny_videos: [nope]\n",
+		"one word":       "name: words\nclaudes_game_words: [lonely]\n",
+		"prompt count":   "name: libs\nmad_lib_templates:\n  - template: 'A {noun} and a {verb}'\n    prompts: [noun]\n",
+		"prompt order":   "name: libs\nmad_lib_templates:\n  - template: 'A {noun} and a {verb}'\n    prompts: [verb, noun]\n",
+		"duplicate name": "name: classic\nnouns: [hat]\n",
+		"unclosed {":     "name: libs\nmad_lib_templates:\n  - template: 'A {noun and a {verb}'\n",
+		"bad trivia":     "name: quiz\ntrivia_questions:\n  - question: Pick one\n    choices: [A, B]\n    answer: C\n",
+	}
+	for what, data := range bad {
+		dir := t.TempDir()
+		writePack(t, dir, "pack.yaml", data)
+		if _, err := LoadContentLibrary(dir); err == nil {
+			t.Errorf("Expected a pack with %s to be rejected", what)
+		}
+	}
+
+	for _, template := range madLibTemplates {
+		if err := template.Normalize(); err != nil {
+			t.Errorf("Built-in template %q is invalid: %v", template.Template, err)
+		}
+	}
+	if _, err := LoadContentLibrary("content"); err != nil {
+		t.Errorf("Expected the shipped packs to load, got %v", err)
+	}
+}
+
+func TestContentLibraryReloads(t *testing.T) {
+	dir := t.TempDir()
+	writePack(t, dir, "movies.yaml", "name: movies\ncharade_topics: [Jaws]\n")
+	lib, err := LoadContentLibrary(dir)
+	if err != nil {
+		t.Fatalf("LoadContentLibrary failed: %v", err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go lib.Watch(5*time.Millisecond, stop)
+
+	waitFor := func(what string, ok func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !ok() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	writePack(t, dir, "sports.json", `{"name": "sports", "items_to_find": ["ball"]}`)
+	waitFor("the new pack", func() bool { return lib.Has("sports") })
+
+	// A broken edit keeps the packs that were loaded
+	writePack(t, dir, "movies.yaml", "name: movies\ncharade_topics: [Jaws, Alien]\nbogus: true\n")
+	time.Sleep(50 * time.Millisecond)
+	if topics := lib.Content([]string{"movies"}).CharadeTopics; len(topics) != 1 {
+		t.Errorf("Expected the broken edit to be ignored, got %v", topics)
+	}
+
+	writePack(t, dir, "movies.yaml", "name: movies\ncharade_topics: [Jaws, Alien, Heat]\n")
+	waitFor("the fixed pack", func() bool { return len(lib.Content([]string{"movies"}).CharadeTopics) == 3 })
+
+	os.Remove(filepath.Join(dir, "sports.json"))
+	waitFor("the removed pack to go", func() bool { return !lib.Has("sports") })
+}
+
+func TestContentLibraryAddTemplate(t *testing.T) {
+	dir := t.TempDir()
+	lib, err := LoadContentLibrary(dir)
+	if err != nil {
+		t.Fatalf("LoadContentLibrary failed: %v", err)
+	}
+
+	added, err := lib.AddTemplate(MadLibTemplate{Template: "My {noun} can {verb}!"})
+	if err != nil {
+		t.Fatalf("AddTemplate failed: %v", err)
+	}
+	if !reflect.DeepEqual(added.Prompts, []string{"noun", "verb"}) {
+		t.Errorf("Expected prompts read from the placeholders, got %v", added.Prompts)
+	}
+	if _, err := lib.AddTemplate(MadLibTemplate{Template: "A {noun}", Prompts: []string{"verb"}}); err == nil {
+		t.Error("Expected a template whose prompts don't match to be refused")
+	}
+	if _, err := lib.AddTemplate(MadLibTemplate{Template: "Two {adjective} {noun}s"}); err != nil {
+		t.Fatalf("AddTemplate failed: %v", err)
+	}
+
+	// Saved templates are a pack rated for everyone, so rooms get them by
+	// default, and they're still there after a fresh load
+	if templates := lib.Content(nil).MadLibTemplates; len(templates) != 2+len(madLibTemplates) {
+		t.Errorf("Expected the custom templates alongside the built-in ones, got %d", len(templates))
+	}
+	reloaded, err := LoadContentLibrary(dir)
+	if err != nil {
+		t.Fatalf("Reloading the saved templates failed: %v", err)
+	}
+	if templates := reloaded.Content([]string{CustomPack}).MadLibTemplates; len(templates) != 2 {
+		t.Errorf("Expected 2 saved templates, got %v", templates)
+	}
+
+	if _, err := NewContentLibrary("").AddTemplate(MadLibTemplate{Template: "A {noun}"}); err == nil {
+		t.Error("Expected a library without a directory to refuse templates")
+	}
+}
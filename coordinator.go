@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
 	"log"
+	"sort"
 	"sync"
+	"time"
 )
 
 // GameCoordinator manages all game actors
 type GameCoordinator struct {
-	games map[string]*GameActor
-	mu    sync.RWMutex
+	games  map[string]*GameActor
+	mu     sync.RWMutex
+	scores *ScoreStore // nil if scores aren't kept
+
+	// Event logs of closed rooms, oldest first
+	replays []*EventLog
 }
 
+// maxArchivedReplays is how many closed rooms' event logs are kept
+const maxArchivedReplays = 50
+
 // NewGameCoordinator creates a new game coordinator
 func NewGameCoordinator() *GameCoordinator {
 	return &GameCoordinator{
@@ -24,20 +34,25 @@ func (gc *GameCoordinator) GetOrCreateGame(gameID string) *GameActor {
 	game, exists := gc.games[gameID]
 	gc.mu.RUnlock()
 
-	if exists {
+	if exists && !game.Closing() {
 		return game
 	}
 
 	gc.mu.Lock()
 	defer gc.mu.Unlock()
 
-	// Double-check after acquiring write lock
-	if game, exists := gc.games[gameID]; exists {
+	// Double-check after acquiring write lock. A closing room is on its
+	// way out, so it's replaced with a fresh one.
+	if game, exists := gc.games[gameID]; exists && !game.Closing() {
 		return game
 	}
 
 	// Create new game actor
 	game = NewGameActor(gameID)
+	game.OnFailure(func(f Failure) { gc.handleGameFailure(gameID, game, f) })
+	if gc.scores != nil {
+		game.OnGameOver(gc.recordGame)
+	}
 	game.Start()
 	gc.games[gameID] = game
 	log.Printf("Created new game: %s", gameID)
@@ -45,6 +60,20 @@ func (gc *GameCoordinator) GetOrCreateGame(gameID string) *GameActor {
 	return game
 }
 
+// UseScoreStore records every completed game in store. Only rooms created
+// afterwards are recorded, so call it before serving.
+func (gc *GameCoordinator) UseScoreStore(store *ScoreStore) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	gc.scores = store
+}
+
+func (gc *GameCoordinator) recordGame(record GameRecord) {
+	if err := gc.scores.RecordGame(record); err != nil {
+		log.Printf("Failed to record %s game in %s: %v", record.Game, record.Room, err)
+	}
+}
+
 // GetGame gets an existing game without creating it
 func (gc *GameCoordinator) GetGame(gameID string) *GameActor {
 	gc.mu.RLock()
@@ -52,22 +81,156 @@ func (gc *GameCoordinator) GetGame(gameID string) *GameActor {
 	return gc.games[gameID]
 }
 
-// RemoveEmptyGames removes games with no players
+// handleGameFailure tells players about a room that recovered from a panic,
+// or closes a room whose supervisor gave up on it so players rejoin fresh
+func (gc *GameCoordinator) handleGameFailure(gameID string, game *GameActor, f Failure) {
+	if f.Action != StrategyStop {
+		log.Printf("Game %s reset after a failure (%d so far)", gameID, f.Restarts)
+		game.Send(RoomResetMsg{Reason: "Something went wrong, so the room was reset. Scores are kept."})
+		return
+	}
+
+	gc.mu.Lock()
+	if gc.games[gameID] == game {
+		delete(gc.games, gameID)
+	}
+	gc.mu.Unlock()
+
+	log.Printf("Game %s stopped after %d failures", gameID, f.Restarts)
+	game.Stop()
+	gc.archive(game)
+	game.CloseConnections(RoomResetEvent{Action: "room-reset", Reason: "Something went wrong and the room was closed."})
+}
+
+// RemoveEmptyGames removes games with no players or spectators. Games are
+// asked for their state without holding the lock, so a stuck game can't
+// freeze the server. Empty ones are checked again under their own lock and
+// marked closing, which turns joins away, before they're removed.
 func (gc *GameCoordinator) RemoveEmptyGames() {
+	gc.mu.RLock()
+	games := make(map[string]*GameActor, len(gc.games))
+	for id, game := range gc.games {
+		games[id] = game
+	}
+	gc.mu.RUnlock()
+
+	var empty []string
+	for id, game := range games {
+		if gc.isEmpty(id, game, askTimeout) {
+			empty = append(empty, id)
+		}
+	}
+	if len(empty) == 0 {
+		return
+	}
+
+	var removed []*GameActor
+	for _, id := range empty {
+		if games[id].CloseIfEmpty() {
+			removed = append(removed, games[id])
+		}
+	}
+
+	gc.mu.Lock()
+	for _, game := range removed {
+		// A join may already have replaced the closing room
+		if gc.games[game.id] == game {
+			delete(gc.games, game.id)
+		}
+		log.Printf("Removed empty game: %s", game.id)
+	}
+	gc.mu.Unlock()
+
+	for _, game := range removed {
+		game.Stop()
+		gc.archive(game)
+	}
+}
+
+// archive keeps a closed room's event log for replay
+func (gc *GameCoordinator) archive(game *GameActor) {
+	events := game.EventLog()
+	if len(events.Events) == 0 {
+		return
+	}
+
 	gc.mu.Lock()
 	defer gc.mu.Unlock()
+	gc.replays = append(gc.replays, events)
+	if len(gc.replays) > maxArchivedReplays {
+		gc.replays = gc.replays[len(gc.replays)-maxArchivedReplays:]
+	}
+}
 
-	for id, game := range gc.games {
-		responseChan := make(chan *GameState, 1)
-		game.Send(GetGameStateMsg{ResponseChan: responseChan})
-		state := <-responseChan
-
-		if len(state.Players) == 0 {
-			game.Stop()
-			delete(gc.games, id)
-			log.Printf("Removed empty game: %s", id)
+// EventLog finds a closed room's session by its log ID. Live rooms aren't
+// replayable: their logs would give away the game in progress. Returns nil
+// if there's no such session.
+func (gc *GameCoordinator) EventLog(id string) *EventLog {
+	gc.mu.RLock()
+	defer gc.mu.RUnlock()
+	for _, events := range gc.replays {
+		if events.ID == id {
+			return events
 		}
 	}
+	return nil
+}
+
+// ReplaySummary describes a session available for replay
+type ReplaySummary struct {
+	ID      string    `json:"id"`
+	Room    string    `json:"room"`
+	Started time.Time `json:"started"`
+	Events  int       `json:"events"`
+}
+
+// Replays lists archived sessions, newest first
+func (gc *GameCoordinator) Replays() []ReplaySummary {
+	gc.mu.RLock()
+	defer gc.mu.RUnlock()
+
+	summaries := make([]ReplaySummary, 0, len(gc.replays))
+	for _, events := range gc.replays {
+		summaries = append(summaries, ReplaySummary{
+			ID:      events.ID,
+			Room:    events.Room,
+			Started: events.Started,
+			Events:  len(events.Events),
+		})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Started.After(summaries[j].Started) })
+	return summaries
+}
+
+// askTimeout is how long a game has to report its state
+const askTimeout = 5 * time.Second
+
+// isEmpty asks a game whether it has any players. A game that doesn't
+// answer in time is logged and treated as not empty.
+func (gc *GameCoordinator) isEmpty(id string, game *GameActor, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	state, err := game.GetState(ctx)
+	if err != nil {
+		log.Printf("Game %s did not report its state: %v", id, err)
+		return false
+	}
+	return len(state.Players) == 0 && state.Spectators == 0
+}
+
+// MailboxStats returns mailbox counters for every game, sorted by name
+func (gc *GameCoordinator) MailboxStats() []MailboxStats {
+	gc.mu.RLock()
+	stats := make([]MailboxStats, 0, len(gc.games))
+	for _, game := range gc.games {
+		stats = append(stats, game.Stats())
+	}
+	gc.mu.RUnlock()
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
 }
 
 // Stop stops all game actors
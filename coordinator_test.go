@@ -98,6 +98,36 @@ func TestCoordinatorRemoveEmptyGames(t *testing.T) {
 	}
 }
 
+func TestCoordinatorReplacesClosingRoom(t *testing.T) {
+	gc := NewGameCoordinator()
+	defer gc.Stop()
+
+	game := gc.GetOrCreateGame("game1")
+	if !game.CloseIfEmpty() {
+		t.Fatal("Expected an empty room to close")
+	}
+
+	// A join that got the room before it closed is turned away, and the
+	// next lookup gets a fresh room
+	err := askJoin(t, game, PlayerJoinMsg{GameID: "game1", PlayerID: "player1", PlayerName: "Alice"})
+	if perr, ok := err.(*ProtocolError); !ok || perr.Code != ErrCodeRoomUnavailable {
+		t.Errorf("Expected the closing room to turn the join away, got %v", err)
+	}
+	fresh := gc.GetOrCreateGame("game1")
+	if fresh == game {
+		t.Fatal("Expected the closing room to be replaced")
+	}
+	askJoin(t, fresh, PlayerJoinMsg{GameID: "game1", PlayerID: "player1", PlayerName: "Alice"})
+	if fresh.CloseIfEmpty() {
+		t.Error("Expected a room with a player not to close")
+	}
+
+	gc.RemoveEmptyGames()
+	if gc.GetGame("game1") != fresh {
+		t.Error("Expected the fresh room to survive cleanup")
+	}
+}
+
 func TestCoordinatorConcurrency(t *testing.T) {
 	gc := NewGameCoordinator()
 	defer gc.Stop()
@@ -162,3 +192,79 @@ func TestCoordinatorStop(t *testing.T) {
 		t.Errorf("Expected 0 games after stop, got %d", count)
 	}
 }
+
+// panickyGame blows up on any submission, like a game with an index bug
+type panickyGame struct {
+	*ClaudesGame
+}
+
+func (g *panickyGame) SubmitAnswer(playerID, answer string) bool {
+	var words []string
+	return words[len(answer)] == ""
+}
+
+func (g *panickyGame) Submit(playerID, answer string) (accepted, complete bool) {
+	return true, g.SubmitAnswer(playerID, answer)
+}
+
+func TestCoordinatorRoomSurvivesPanic(t *testing.T) {
+	gc := NewGameCoordinator()
+	defer gc.Stop()
+
+	game := gc.GetOrCreateGame("game1")
+	game.Send(PlayerJoinMsg{
+		GameID:     "game1",
+		PlayerID:   "player1",
+		PlayerName: "Alice",
+		Conn:       nil,
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	game.mu.Lock()
+	game.players["player1"].Score = 5
+	game.state = "playing"
+	game.currentGame = "claudesgame"
+	game.game = &panickyGame{NewClaudesGame(GameConfig{})}
+	game.mu.Unlock()
+
+	game.Send(SubmitWordMsg{PlayerID: "player1", Word: "oops"})
+	time.Sleep(50 * time.Millisecond)
+
+	if gc.GetGame("game1") != game {
+		t.Fatal("Room was dropped after a single failure")
+	}
+
+	state := askState(t, game)
+
+	if state.State != "lobby" {
+		t.Errorf("Expected room reset to lobby, got %s", state.State)
+	}
+	if p := state.Players["player1"]; p == nil || p.Score != 5 {
+		t.Errorf("Expected player and score to survive the reset, got %+v", p)
+	}
+}
+
+func TestCoordinatorArchivesClosedRooms(t *testing.T) {
+	gc := NewGameCoordinator()
+	defer gc.Stop()
+
+	game := gc.GetOrCreateGame("game1")
+	logID := game.LogID()
+	askJoin(t, game, PlayerJoinMsg{GameID: "game1", PlayerID: "player1", PlayerName: "Alice"})
+
+	// Live rooms can't be replayed, by room or log ID
+	if gc.EventLog("game1") != nil || gc.EventLog(logID) != nil || len(gc.Replays()) != 0 {
+		t.Fatal("Expected the live room's log to be kept back")
+	}
+
+	game.Send(PlayerLeaveMsg{PlayerID: "player1"})
+	gc.RemoveEmptyGames()
+
+	events := gc.EventLog(logID)
+	if events == nil || len(events.Events) != 2 {
+		t.Fatalf("Expected the closed room's join and leave, got %+v", events)
+	}
+	if replays := gc.Replays(); len(replays) != 1 || replays[0].ID != logID {
+		t.Errorf("Expected one archived session, got %+v", replays)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchOp is a single JSON Patch (RFC 6902) operation. Only add, remove and
+// replace are ever produced. Value is always sent so null values survive.
+type PatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// toJSONValue round-trips v through JSON so it can be diffed generically
+func toJSONValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DiffJSON returns the operations that turn old into new. Both must be
+// generic JSON values (maps, slices, strings, float64s, bools, nil).
+// Objects are diffed key by key; arrays of equal length element by element,
+// otherwise they are replaced whole.
+func DiffJSON(old, new interface{}) []PatchOp {
+	return diffJSON("", old, new, nil)
+}
+
+func diffJSON(path string, old, new interface{}, ops []PatchOp) []PatchOp {
+	switch o := old.(type) {
+	case map[string]interface{}:
+		n, ok := new.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(o)+len(n))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		for k := range n {
+			if _, exists := o[k]; !exists {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			childPath := path + "/" + escapePointer(k)
+			ov, inOld := o[k]
+			nv, inNew := n[k]
+			switch {
+			case !inNew:
+				ops = append(ops, PatchOp{Op: "remove", Path: childPath})
+			case !inOld:
+				ops = append(ops, PatchOp{Op: "add", Path: childPath, Value: nv})
+			default:
+				ops = diffJSON(childPath, ov, nv, ops)
+			}
+		}
+		return ops
+
+	case []interface{}:
+		n, ok := new.([]interface{})
+		if !ok || len(n) != len(o) {
+			break
+		}
+		for i := range o {
+			ops = diffJSON(path+"/"+strconv.Itoa(i), o[i], n[i], ops)
+		}
+		return ops
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		ops = append(ops, PatchOp{Op: "replace", Path: path, Value: new})
+	}
+	return ops
+}
+
+// ApplyPatch applies ops to a generic JSON document and returns the result.
+// The input document is not modified.
+func ApplyPatch(doc interface{}, ops []PatchOp) (interface{}, error) {
+	doc = deepCopyJSON(doc)
+	for _, op := range ops {
+		if op.Path == "" {
+			if op.Op == "remove" {
+				return nil, fmt.Errorf("cannot remove the document root")
+			}
+			doc = deepCopyJSON(op.Value)
+			continue
+		}
+
+		tokens := strings.Split(op.Path[1:], "/")
+		parent := doc
+		for _, token := range tokens[:len(tokens)-1] {
+			child, err := jsonChild(parent, unescapePointer(token))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op.Path, err)
+			}
+			parent = child
+		}
+
+		last := unescapePointer(tokens[len(tokens)-1])
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if op.Op == "remove" {
+				delete(p, last)
+			} else {
+				p[last] = deepCopyJSON(op.Value)
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(last)
+			if err != nil || i < 0 || i >= len(p) || op.Op != "replace" {
+				return nil, fmt.Errorf("%s: unsupported array operation", op.Path)
+			}
+			p[i] = deepCopyJSON(op.Value)
+		default:
+			return nil, fmt.Errorf("%s: parent is not a container", op.Path)
+		}
+	}
+	return doc, nil
+}
+
+func jsonChild(parent interface{}, token string) (interface{}, error) {
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		child, ok := p[token]
+		if !ok {
+			return nil, fmt.Errorf("missing key %q", token)
+		}
+		return child, nil
+	case []interface{}:
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(p) {
+			return nil, fmt.Errorf("bad index %q", token)
+		}
+		return p[i], nil
+	}
+	return nil, fmt.Errorf("cannot descend into %q", token)
+}
+
+func deepCopyJSON(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			out[k] = deepCopyJSON(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, val := range t {
+			out[i] = deepCopyJSON(val)
+		}
+		return out
+	}
+	return v
+}
+
+// escapePointer escapes a JSON Pointer (RFC 6901) reference token
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+func unescapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffJSONRoundTrip(t *testing.T) {
+	old, _ := toJSONValue(map[string]interface{}{
+		"game_state": "lobby",
+		"players": []interface{}{
+			map[string]interface{}{"id": "1", "score": 0},
+		},
+		"story":       "gone soon",
+		"a/b~c":       1,
+		"needs_input": false,
+	})
+	new, _ := toJSONValue(map[string]interface{}{
+		"game_state": "playing",
+		"players": []interface{}{
+			map[string]interface{}{"id": "1", "score": 3},
+		},
+		"time_remaining": 30,
+		"a/b~c":          2,
+		"needs_input":    nil,
+	})
+
+	ops := DiffJSON(old, new)
+	patched, err := ApplyPatch(old, ops)
+	if err != nil {
+		t.Fatalf("ApplyPatch failed: %v", err)
+	}
+	if !reflect.DeepEqual(patched, new) {
+		t.Errorf("Patched document differs:\n got  %v\n want %v\n ops %v", patched, new, ops)
+	}
+}
+
+func TestDiffJSONOnlyChangedFields(t *testing.T) {
+	old, _ := toJSONValue(map[string]interface{}{
+		"players": []interface{}{
+			map[string]interface{}{"id": "1", "score": 0},
+			map[string]interface{}{"id": "2", "score": 0},
+		},
+	})
+	new, _ := toJSONValue(map[string]interface{}{
+		"players": []interface{}{
+			map[string]interface{}{"id": "1", "score": 0},
+			map[string]interface{}{"id": "2", "score": 1},
+		},
+	})
+
+	ops := DiffJSON(old, new)
+	if len(ops) != 1 {
+		t.Fatalf("Expected 1 op, got %d: %v", len(ops), ops)
+	}
+	if ops[0].Op != "replace" || ops[0].Path != "/players/1/score" {
+		t.Errorf("Expected replace of /players/1/score, got %v", ops[0])
+	}
+}
+
+func TestDiffJSONArrayLengthChangeReplaces(t *testing.T) {
+	old, _ := toJSONValue(map[string]interface{}{"voted_players": []string{"a"}})
+	new, _ := toJSONValue(map[string]interface{}{"voted_players": []string{"a", "b"}})
+
+	ops := DiffJSON(old, new)
+	if len(ops) != 1 || ops[0].Path != "/voted_players" || ops[0].Op != "replace" {
+		t.Errorf("Expected whole array replace, got %v", ops)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// MaxLoggedEvents caps a room's event log. Later events are dropped and the
+// log is marked truncated, so its replay stops short.
+const MaxLoggedEvents = 50000
+
+// EventLog is everything that happened in a room, in the order the
+// GameActor handled it. Replaying it through a fresh GameActor with the
+// same seed reproduces the session.
+type EventLog struct {
+	ID        string        `json:"id"`
+	Room      string        `json:"room"`
+	Seed      int64         `json:"seed"`
+	Started   time.Time     `json:"started"`
+	Events    []LoggedEvent `json:"events"`
+	Truncated bool          `json:"truncated,omitempty"`
+
+	// The content each round was prepared with, by hash in round order,
+	// so a replay picks from the same lists after packs change
+	Rounds  []string            `json:"rounds,omitempty"`
+	Content map[string]*Content `json:"content,omitempty"`
+}
+
+// LoggedEvent is one message handled by a GameActor
+type LoggedEvent struct {
+	Seq     int             `json:"seq"`
+	Time    time.Time       `json:"time"`
+	Type    string          `json:"type"`
+	Message json.RawMessage `json:"message"`
+}
+
+// loggedMessages are the messages that change a room and so go in its
+// event log, by the type name they're logged under. Queries, pings and
+// resends don't change anything and are left out.
+var loggedMessages = map[string]ActorMessage{
+	"join":               PlayerJoinMsg{},
+	"leave":              PlayerLeaveMsg{},
+	"disconnect":         PlayerDisconnectMsg{},
+	"resume":             PlayerResumeMsg{},
+	"disconnect-timeout": DisconnectTimeoutMsg{},
+	"next-game":          NextGameMsg{},
+	"kick":               KickPlayerMsg{},
+	"skip":               SkipGameMsg{},
+	"lock":               LockRoomMsg{},
+	"set-selection":      SetSelectionMsg{},
+	"enable-game":        EnableGameMsg{},
+	"update-settings":    UpdateSettingsMsg{},
+	"set-teams":          SetTeamsMsg{},
+	"assign-team":        AssignTeamMsg{},
+	"balance-teams":      BalanceTeamsMsg{},
+	"rematch":            RematchMsg{},
+	"set-packs":          SetPacksMsg{},
+	"add-template":       AddTemplateMsg{},
+	"clear-templates":    ClearTemplatesMsg{},
+	"request-prompt":     RequestPromptMsg{},
+	"submit-word":        SubmitWordMsg{},
+	"vote":               VoteMsg{},
+	"timer-tick":         TimerTickMsg{},
+}
+
+var loggedTypeNames = func() map[reflect.Type]string {
+	names := make(map[reflect.Type]string, len(loggedMessages))
+	for name, msg := range loggedMessages {
+		names[reflect.TypeOf(msg)] = name
+	}
+	return names
+}()
+
+func newEventLog(room string, seed int64) *EventLog {
+	started := time.Now()
+	return &EventLog{
+		ID:      fmt.Sprintf("%s-%d", room, started.UnixNano()),
+		Room:    room,
+		Seed:    seed,
+		Started: started,
+	}
+}
+
+// append adds msg to the log if it's a logged message type
+func (l *EventLog) append(msg ActorMessage) {
+	name, ok := loggedTypeNames[reflect.TypeOf(msg)]
+	if !ok || l.Truncated {
+		return
+	}
+	if len(l.Events) >= MaxLoggedEvents {
+		l.Truncated = true
+		return
+	}
+
+	data, err := json.Marshal(redactTokens(msg))
+	if err != nil {
+		return
+	}
+	l.Events = append(l.Events, LoggedEvent{
+		Seq:     len(l.Events) + 1,
+		Time:    time.Now(),
+		Type:    name,
+		Message: data,
+	})
+}
+
+// appendRound records the content a round was prepared with. Rooms play
+// many rounds from the same packs, so each distinct snapshot is kept once.
+func (l *EventLog) appendRound(content *Content) {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:8])
+	if l.Content == nil {
+		l.Content = make(map[string]*Content)
+	}
+	if _, exists := l.Content[hash]; !exists {
+		l.Content[hash] = content
+	}
+	l.Rounds = append(l.Rounds, hash)
+}
+
+// roundContent returns the content the log's round'th round (from 0) was
+// prepared with, or nil for logs that don't have it
+func (l *EventLog) roundContent(round int) *Content {
+	if round >= len(l.Rounds) {
+		return nil
+	}
+	return l.Content[l.Rounds[round]]
+}
+
+// copy returns a snapshot of the log safe to hand out. Content snapshots
+// are never changed once logged, so they're shared.
+func (l *EventLog) copy() *EventLog {
+	c := *l
+	c.Events = append([]LoggedEvent(nil), l.Events...)
+	c.Rounds = append([]string(nil), l.Rounds...)
+	if l.Content != nil {
+		c.Content = make(map[string]*Content, len(l.Content))
+		for hash, content := range l.Content {
+			c.Content[hash] = content
+		}
+	}
+	return &c
+}
+
+// redactTokens swaps session tokens and signed-in user names for a hash of
+// them. Logs are served over HTTP, and a real token would let anyone take
+// over the session; the hash still matches a join with its resumes and
+// bans, and still marks who was signed in, when replayed.
+func redactTokens(msg ActorMessage) ActorMessage {
+	switch m := msg.(type) {
+	case PlayerJoinMsg:
+		m.Token = hashToken(m.Token)
+		m.RemoteUser = hashToken(m.RemoteUser)
+		return m
+	case PlayerResumeMsg:
+		m.Token = hashToken(m.Token)
+		return m
+	}
+	return msg
+}
+
+// logHashKey keys hashToken. It's picked per process so a served log can't
+// be checked against guessed names or tokens; hashes only have to match
+// within the same run.
+var logHashKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("generating log hash key: %v", err))
+	}
+	return key
+}()
+
+func hashToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, logHashKey)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// Decode returns the message this event logged
+func (e LoggedEvent) Decode() (ActorMessage, error) {
+	proto, ok := loggedMessages[e.Type]
+	if !ok {
+		return nil, fmt.Errorf("event %d: unknown type %q", e.Seq, e.Type)
+	}
+	ptr := reflect.New(reflect.TypeOf(proto))
+	if err := json.Unmarshal(e.Message, ptr.Interface()); err != nil {
+		return nil, fmt.Errorf("event %d: %w", e.Seq, err)
+	}
+	return ptr.Elem().Interface().(ActorMessage), nil
+}
+
+// ReplayStep is the room as it stood after one replayed event
+type ReplayStep struct {
+	Seq   int        `json:"seq"`
+	Time  time.Time  `json:"time"`
+	Type  string     `json:"type"`
+	State *GameState `json:"state"`
+}
+
+// ReplayLog plays a log through a fresh GameActor, returning the room's
+// state after each event. The replay never starts timers of its own; the
+// ticks and timeouts that happened are in the log, and its rounds use the
+// content they were logged with rather than the packs loaded now.
+func ReplayLog(ctx context.Context, l *EventLog) ([]ReplayStep, error) {
+	ga := NewGameActorWithSeed(l.Room, l.Seed)
+	ga.replaying = true
+	ga.replayOf = l
+	ga.Start()
+	defer ga.Stop()
+
+	steps := make([]ReplayStep, 0, len(l.Events))
+	for _, event := range l.Events {
+		msg, err := event.Decode()
+		if err != nil {
+			return steps, err
+		}
+		if err := ga.Send(msg); err != nil {
+			return steps, fmt.Errorf("event %d: %w", event.Seq, err)
+		}
+
+		state, err := ga.GetState(ctx)
+		if err != nil {
+			return steps, fmt.Errorf("event %d: %w", event.Seq, err)
+		}
+		steps = append(steps, ReplayStep{
+			Seq:   event.Seq,
+			Time:  event.Time,
+			Type:  event.Type,
+			State: state,
+		})
+	}
+	return steps, nil
+}
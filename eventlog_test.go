@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayReproducesSession(t *testing.T) {
+	ga := NewGameActorWithSeed("replay-test", 42)
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "replay-test", PlayerID: "p1", PlayerName: "Alice", Token: "secret-1", RemoteUser: "secret-alice"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "replay-test", PlayerID: "p2", PlayerName: "Bob", Token: "secret-2"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "replay-test", PlayerID: "p3", PlayerName: "Carol", Token: "secret-3"})
+
+	// A round of Claude's Game, then a disconnect, a resume and a leave
+	ga.Send(NextGameMsg{PlayerID: "p1", Game: "claudesgame"})
+	for _, id := range []string{"p1", "p2", "p3"} {
+		ga.Send(NextGameMsg{PlayerID: id})
+	}
+	for _, id := range []string{"p1", "p2", "p3"} {
+		ga.Send(SubmitWordMsg{PlayerID: id, Word: "answer " + id})
+	}
+	ga.Send(VoteMsg{PlayerID: "p1", VotedForID: "p3"})
+	ga.Send(VoteMsg{PlayerID: "p2", VotedForID: "p3"})
+	ga.Send(VoteMsg{PlayerID: "p3", VotedForID: "p1"})
+	ga.Send(PlayerDisconnectMsg{PlayerID: "p2"})
+	askResume(t, ga, "secret-2")
+	ga.Send(PlayerLeaveMsg{PlayerID: "p1"})
+
+	// The seed decides the next game
+	ga.Send(NextGameMsg{PlayerID: "p2"})
+	want := askState(t, ga)
+
+	data, err := json.Marshal(ga.EventLog())
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if strings.Contains(string(data), "secret-") {
+		t.Error("Expected session tokens and user names to be redacted from the log")
+	}
+	var events EventLog
+	if err := json.Unmarshal(data, &events); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	steps, err := ReplayLog(ctx, &events)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(steps) != len(events.Events) {
+		t.Fatalf("Expected a step per event, got %d for %d", len(steps), len(events.Events))
+	}
+
+	got := steps[len(steps)-1].State
+	if got.State != want.State || got.CurrentGame != want.CurrentGame || got.HostID != want.HostID {
+		t.Errorf("Expected %s in %s hosted by %s, replay got %s in %s hosted by %s",
+			want.CurrentGame, want.State, want.HostID, got.CurrentGame, got.State, got.HostID)
+	}
+	if len(got.Players) != len(want.Players) {
+		t.Fatalf("Expected %d players, replay got %d", len(want.Players), len(got.Players))
+	}
+	for id, p := range want.Players {
+		if got.Players[id].Score != p.Score || got.Players[id].Disconnected != p.Disconnected {
+			t.Errorf("Player %s: expected %+v, replay got %+v", id, p, got.Players[id])
+		}
+	}
+}
+
+func TestEventLogSkipsQueries(t *testing.T) {
+	ga := NewGameActor("log-test")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "log-test", PlayerID: "p1", PlayerName: "Alice"})
+	ga.Send(PingMsg{PlayerID: "p1"})
+	ga.Send(ResyncMsg{PlayerID: "p1"})
+	askState(t, ga)
+
+	events := ga.EventLog().Events
+	if len(events) != 1 || events[0].Type != "join" {
+		t.Errorf("Expected only the join to be logged, got %+v", events)
+	}
+}
+
+func TestRedactTokensHidesNames(t *testing.T) {
+	msg := redactTokens(PlayerJoinMsg{PlayerID: "p1", Token: "secret", RemoteUser: "alice"}).(PlayerJoinMsg)
+	if msg.Token == "secret" || msg.RemoteUser == "alice" {
+		t.Fatalf("Expected the token and user to be hashed, got %+v", msg)
+	}
+
+	// A plain hash of a guessed name must not match the logged one
+	sum := sha256.Sum256([]byte("alice"))
+	if msg.RemoteUser == hex.EncodeToString(sum[:8]) {
+		t.Error("Expected the logged user to be keyed, not a plain hash")
+	}
+
+	resume := redactTokens(PlayerResumeMsg{Token: "secret"}).(PlayerResumeMsg)
+	if resume.Token != msg.Token {
+		t.Errorf("Expected a resume to hash to its join's token, got %q and %q", resume.Token, msg.Token)
+	}
+}
+
+func TestReplayUsesLoggedContent(t *testing.T) {
+	dir := t.TempDir()
+	writePack(t, dir, "movies.yaml", "name: movies\ncharade_topics: [Jaws]\n")
+	lib, err := LoadContentLibrary(dir)
+	if err != nil {
+		t.Fatalf("LoadContentLibrary failed: %v", err)
+	}
+	defer func(previous *ContentLibrary) { contentPacks = previous }(contentPacks)
+	contentPacks = lib
+
+	ga := NewGameActorWithSeed("content-test", 7)
+	ga.Start()
+	defer ga.Stop()
+	askJoin(t, ga, PlayerJoinMsg{GameID: "content-test", PlayerID: "p1", PlayerName: "Alice"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "content-test", PlayerID: "p2", PlayerName: "Bob"})
+	ga.Send(SetPacksMsg{PlayerID: "p1", Packs: []string{"movies"}})
+	ga.Send(NextGameMsg{PlayerID: "p1", Game: "charades"})
+	askState(t, ga)
+
+	data, err := json.Marshal(ga.EventLog())
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var events EventLog
+	if err := json.Unmarshal(data, &events); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(events.Rounds) != 1 {
+		t.Fatalf("Expected the round's content in the log, got %v", events.Rounds)
+	}
+
+	// The pack changes after the session; the replay still plays Jaws
+	writePack(t, dir, "movies.yaml", "name: movies\ncharade_topics: [Alien]\n")
+	if err := lib.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	replay := NewGameActorWithSeed(events.Room, events.Seed)
+	replay.replaying = true
+	replay.replayOf = &events
+	replay.Start()
+	defer replay.Stop()
+	for _, event := range events.Events {
+		msg, err := event.Decode()
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		replay.Send(msg)
+	}
+	askState(t, replay)
+
+	replay.mu.Lock()
+	defer replay.mu.Unlock()
+	if topic := replay.pending.(*Charades).topic; topic != "Jaws" {
+		t.Errorf("Expected the replay to use the logged topic, got %q", topic)
+	}
+}
+
+func TestReplayEndpointServesClosedRoomsOnly(t *testing.T) {
+	defer func(previous *GameCoordinator) { coordinator = previous }(coordinator)
+	coordinator = NewGameCoordinator()
+	defer coordinator.Stop()
+
+	game := coordinator.GetOrCreateGame("room")
+	logID := game.LogID()
+	askJoin(t, game, PlayerJoinMsg{GameID: "room", PlayerID: "p1", PlayerName: "Alice"})
+
+	get := func(id string) int {
+		rec := httptest.NewRecorder()
+		handleReplay(rec, httptest.NewRequest(http.MethodGet, "/replay/"+id, nil))
+		return rec.Code
+	}
+	if code := get("room"); code != http.StatusNotFound {
+		t.Errorf("Expected a live room not to be replayed, got %d", code)
+	}
+
+	game.Send(PlayerLeaveMsg{PlayerID: "p1"})
+	coordinator.RemoveEmptyGames()
+
+	// Replays wait their turn rather than pile up
+	for i := 0; i < cap(replaySlots); i++ {
+		replaySlots <- struct{}{}
+	}
+	code := get(logID)
+	for i := 0; i < cap(replaySlots); i++ {
+		<-replaySlots
+	}
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("Expected a replay over the cap to be turned away, got %d", code)
+	}
+	if code := get(logID); code != http.StatusOK {
+		t.Errorf("Expected the closed room to replay, got %d", code)
+	}
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"math/rand"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -12,39 +18,209 @@ import (
 // GameActor manages a single game session using the actor model
 type GameActor struct {
 	id          string
-	state       string // "lobby", "instructions", "playing", "voting", "finished"
+	state       string // "lobby", "instructions", "playing", "voting", "finished", "podium"
 	currentGame string
 	players     map[string]*Player
 	game        GameType
-	votes       map[string]string // playerID -> votedForPlayerID
+	votes       map[string]string // playerID -> votedForPlayerID, "" to abstain
 	winners     []string          // names of winners from last vote
 	mu          sync.RWMutex
 	actor       *Actor
+	supervisor  *Supervisor
+
+	// Round timer, owned by the actor. timerStop is non-nil while a ticker
+	// goroutine is feeding TimerTickMsg into the inbox.
+	tickInterval time.Duration
+	timerStop    chan struct{}
+
+	// How long a dropped player is kept before being removed
+	disconnectGrace time.Duration
+
+	// Bumped on every broadcast; clients use it to spot missed patches
+	seq uint64
+
+	// Sizing and overflow policy for each player's outbound queue
+	outbox OutboxConfig
+
+	// Room moderation. hostID is only empty while the room is empty.
+	// awayHost is a host who dropped and handed over, and gets the room
+	// back if they resume.
+	hostID   string
+	awayHost string
+	locked   bool
+	bans     map[string]bool // banned session tokens, remote users and names
+	joins    int             // join counter, gives players a join order
+
+	// Spectators get state updates but aren't players: they don't ready
+	// up, vote, act or score, and games hide secrets from them. They have
+	// no grace period; a dropped spectator just joins again.
+	spectators map[string]*Player
+
+	// How the next game is picked
+	selector *GameSelector
+
+	// House rules set by the host, and the round of the current game
+	settings RoomSettings
+	round    int
+
+	// Content packs the room's games draw from, empty for every pack
+	// rated for everyone
+	packs []string
+
+	// Mad Libs templates the room's players wrote. When there are any,
+	// the room's Mad Libs games use them instead of the packs' templates.
+	templates []MadLibTemplate
+
+	// Scores when the round started, so finished games record the points
+	// each player won in them
+	roundStartScores map[string]int
+	onGameOver       func(GameRecord)
+
+	// The match so far: every finished round, and how many games have
+	// played all their rounds. The match ends on the podium once the
+	// settings' game count or point target is reached.
+	match       []GameRecord
+	gamesPlayed int
+
+	// Every message that changed the room, and the seed of the room's
+	// random choices, so the session can be replayed. A replaying actor
+	// gets its ticks and timeouts from the log instead of timers.
+	seed      int64
+	rng       *rand.Rand
+	events    *EventLog
+	replaying bool
+	replayOf  *EventLog // the log being replayed, for its round content
+
+	// Each round's game gets its own RNG seeded from the room's, so a round
+	// can be reproduced on its own. pending is the game the instructions
+	// describe, created before the round starts.
+	roundSeed int64
+	pending   GameType
+
+	// Voting rules in play: seconds left before the vote closes (0 for no
+	// deadline), the tied players in a runoff, and every vote cast this
+	// round, revealed when the game finishes
+	voteRemaining int
+	runoff        []string
+	voteResults   []VoteResult
+
+	// Team play, nil when off. actingTeam is the team whose member is
+	// acting this round; only they guess, and 0 means anyone may.
+	teams      *Teams
+	actingTeam int
+
+	// Set once the coordinator has found the room empty and is taking it
+	// down. Joins are turned away from then on, so the coordinator can
+	// drop it without racing a join.
+	closing atomic.Bool
 }
 
 // Player represents a player in the game
 type Player struct {
-	ID    string
-	Name  string
-	Score int
-	Ready bool
-	Conn  *websocket.Conn
-	mu    sync.Mutex
+	ID           string
+	Name         string
+	Score        int
+	Ready        bool
+	Token        string // session token used to resume after a dropped connection
+	Disconnected bool
+	Protocol     int    // negotiated wire protocol version
+	RemoteUser   string // authenticated user, empty for guests
+	Role         PlayerRole
+	joinOrder    int
+	disconnects  int // bumped on every disconnect so stale timeouts can be ignored
+	Conn         *websocket.Conn
+	out          *Outbox // drains to Conn; nil while disconnected
+	mu           sync.Mutex
+
+	// Last state sent to this player, used to build deltas. nil means the
+	// next update must be a full snapshot.
+	lastState interface{}
+	lastSeq   uint64
 }
 
 // NewGameActor creates a new game actor
 func NewGameActor(gameID string) *GameActor {
+	return NewGameActorWithSeed(gameID, time.Now().UnixNano())
+}
+
+// NewGameActorWithSeed creates a game actor whose random choices come from
+// seed, so two actors fed the same messages make the same choices
+func NewGameActorWithSeed(gameID string, seed int64) *GameActor {
+	rng := rand.New(rand.NewSource(seed))
 	ga := &GameActor{
-		id:      gameID,
-		state:   "lobby",
-		players: make(map[string]*Player),
+		id:              gameID,
+		state:           "lobby",
+		players:         make(map[string]*Player),
+		bans:            make(map[string]bool),
+		spectators:      make(map[string]*Player),
+		seed:            seed,
+		rng:             rng,
+		events:          newEventLog(gameID, seed),
+		selector:        NewGameSelector(rng),
+		settings:        DefaultRoomSettings(),
+		tickInterval:    time.Second,
+		disconnectGrace: 60 * time.Second,
+		outbox:          DefaultOutboxConfig,
 	}
 
-	// Create the actor with message handler
+	// Create the actor with message handler. A panicking handler resets the
+	// room rather than taking the server down.
 	ga.actor = NewActor(ga.handleMessage, 100)
+	ga.actor.SetMailbox(MailboxConfig{
+		Name:        "game " + gameID,
+		Policy:      MailboxBlock,
+		DeadLetters: DeadLetters,
+	})
+	ga.supervisor = &Supervisor{
+		Strategy:    StrategyRestart,
+		MaxRestarts: 3,
+		Window:      time.Minute,
+		Restart:     ga.restart,
+	}
+	ga.actor.Supervise(ga.supervisor)
 	return ga
 }
 
+// OnFailure registers a callback for handler panics. Must be called before
+// Start.
+func (ga *GameActor) OnFailure(fn func(Failure)) {
+	ga.supervisor.OnFailure = fn
+}
+
+// OnGameOver registers a callback for every completed game. It runs in its
+// own goroutine so slow storage never holds up the room. Must be called
+// before Start.
+func (ga *GameActor) OnGameOver(fn func(GameRecord)) {
+	ga.onGameOver = fn
+}
+
+// restart puts the room back into the lobby after a handler panicked. The
+// game in progress may be corrupt, so it is thrown away; players, their
+// connections and scores are the last good state and are kept.
+func (ga *GameActor) restart() {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	ga.resetToLobby()
+}
+
+// resetToLobby drops any game in progress and goes back to the lobby
+func (ga *GameActor) resetToLobby() {
+	ga.stopTimer()
+	ga.state = "lobby"
+	ga.currentGame = ""
+	ga.game = nil
+	ga.pending = nil
+	ga.votes = nil
+	ga.runoff = nil
+	ga.voteResults = nil
+	ga.winners = nil
+	ga.actingTeam = 0
+	for _, p := range ga.players {
+		p.Ready = false
+	}
+}
+
 // Start starts the game actor
 func (ga *GameActor) Start() {
 	ga.actor.Start()
@@ -52,590 +228,1861 @@ func (ga *GameActor) Start() {
 
 // Stop stops the game actor
 func (ga *GameActor) Stop() {
+	ga.mu.Lock()
+	ga.stopTimer()
+	ga.mu.Unlock()
 	ga.actor.Stop()
 }
 
-// Send sends a message to the game actor
-func (ga *GameActor) Send(msg ActorMessage) {
-	ga.actor.Send(msg)
+// Send sends a message to the game actor. Returns an error if the message
+// was not delivered.
+func (ga *GameActor) Send(msg ActorMessage) error {
+	return ga.actor.Send(msg)
+}
+
+// Stats returns the game actor's mailbox counters
+func (ga *GameActor) Stats() MailboxStats {
+	return ga.actor.Stats()
+}
+
+// Ask sends a request to the game actor and waits for the reply
+func (ga *GameActor) Ask(ctx context.Context, msg ActorMessage) (interface{}, error) {
+	return ga.actor.Ask(ctx, msg)
+}
+
+// GetState asks the game actor for a snapshot of the room
+func (ga *GameActor) GetState(ctx context.Context) (*GameState, error) {
+	reply, err := ga.Ask(ctx, GetGameStateMsg{})
+	if err != nil {
+		return nil, err
+	}
+	return reply.(*GameState), nil
+}
+
+// EventLog returns a copy of the room's event log
+func (ga *GameActor) EventLog() *EventLog {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+	return ga.events.copy()
+}
+
+// CloseIfEmpty marks the room as closing if nobody is in it, and reports
+// whether it did. It checks under the room's own lock, so a join either
+// got in first or is turned away.
+func (ga *GameActor) CloseIfEmpty() bool {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+	if len(ga.players) > 0 || len(ga.spectators) > 0 {
+		return false
+	}
+	ga.closing.Store(true)
+	return true
+}
+
+// Closing reports whether the room has been closed by CloseIfEmpty
+func (ga *GameActor) Closing() bool {
+	return ga.closing.Load()
+}
+
+// LogID identifies this session's event log
+func (ga *GameActor) LogID() string {
+	return ga.events.ID // set once by the constructor
+}
+
+// record appends msg to the event log. Disconnects are recorded by their
+// handler instead, since only the live actor can tell a stale one apart.
+func (ga *GameActor) record(msg ActorMessage) {
+	if ask, ok := msg.(AskMsg); ok {
+		msg = ask.Msg
+	}
+	if _, ok := msg.(PlayerDisconnectMsg); ok {
+		return
+	}
+
+	ga.mu.Lock()
+	ga.events.append(msg)
+	ga.mu.Unlock()
 }
 
 // handleMessage processes incoming messages
 func (ga *GameActor) handleMessage(msg ActorMessage) {
+	ga.record(msg)
+
 	switch m := msg.(type) {
 	case PlayerJoinMsg:
 		ga.handlePlayerJoin(m)
+	case KickPlayerMsg:
+		ga.handleKickPlayer(m)
+	case SkipGameMsg:
+		ga.handleSkipGame(m)
+	case LockRoomMsg:
+		ga.handleLockRoom(m)
+	case SetSelectionMsg:
+		ga.handleSetSelection(m)
+	case EnableGameMsg:
+		ga.handleEnableGame(m)
+	case UpdateSettingsMsg:
+		ga.handleUpdateSettings(m)
+	case SetTeamsMsg:
+		ga.handleSetTeams(m)
+	case AssignTeamMsg:
+		ga.handleAssignTeam(m)
+	case BalanceTeamsMsg:
+		ga.handleBalanceTeams(m)
+	case RematchMsg:
+		ga.handleRematch(m)
+	case SetPacksMsg:
+		ga.handleSetPacks(m)
+	case AddTemplateMsg:
+		ga.handleAddTemplate(m)
+	case ClearTemplatesMsg:
+		ga.handleClearTemplates(m)
+	case PlayerResumeMsg:
+		ga.handlePlayerResume(m)
 	case PlayerLeaveMsg:
 		ga.handlePlayerLeave(m)
+	case PlayerDisconnectMsg:
+		ga.handlePlayerDisconnect(m)
+	case DisconnectTimeoutMsg:
+		ga.handleDisconnectTimeout(m)
 	case NextGameMsg:
 		ga.handleNextGame(m)
 	case PingMsg:
 		ga.handlePing(m)
+	case NotifyPlayerMsg:
+		ga.handleNotifyPlayer(m)
+	case ResyncMsg:
+		ga.handleResync(m)
 	case RequestPromptMsg:
 		ga.handleRequestPrompt(m)
 	case SubmitWordMsg:
 		ga.handleSubmitWord(m)
 	case VoteMsg:
 		ga.handleVote(m)
+	case TimerTickMsg:
+		ga.handleTimerTick(m)
+	case RoomResetMsg:
+		ga.handleRoomReset(m)
 	case BroadcastStateMsg:
 		ga.broadcastState()
+	case AskMsg:
+		ga.handleAsk(m)
+	}
+}
+
+// handleAsk answers requests sent with Ask
+func (ga *GameActor) handleAsk(ask AskMsg) {
+	switch m := ask.Msg.(type) {
+	case PlayerJoinMsg:
+		if perr := ga.handlePlayerJoin(m); perr != nil {
+			ask.Fail(perr)
+		} else {
+			ask.Reply(m.PlayerID)
+		}
+	case PlayerResumeMsg:
+		ask.Reply(ga.handlePlayerResume(m))
 	case GetGameStateMsg:
-		ga.handleGetGameState(m)
+		ask.Reply(ga.handleGetGameState(m))
+	default:
+		ask.Fail(fmt.Errorf("game actor can't answer %T", m))
 	}
 }
 
-func (ga *GameActor) handlePlayerJoin(msg PlayerJoinMsg) {
+// handlePlayerJoin adds a player, or returns why the room turned them away
+func (ga *GameActor) handlePlayerJoin(msg PlayerJoinMsg) *ProtocolError {
 	ga.mu.Lock()
 	defer ga.mu.Unlock()
 
+	if ga.closing.Load() {
+		return &ProtocolError{Code: ErrCodeRoomUnavailable, Message: "The room just closed, try again"}
+	}
+	if ga.isBanned(msg.Token, msg.RemoteUser, msg.PlayerName) {
+		log.Printf("Banned player %s turned away from game %s", msg.PlayerName, ga.id)
+		return &ProtocolError{Code: ErrCodeBanned, Message: "You have been banned from this room"}
+	}
+	// Locking keeps new players out, but anyone may still watch
+	if ga.locked && !msg.Spectate {
+		return &ProtocolError{Code: ErrCodeRoomLocked, Message: "This room is locked"}
+	}
+
+	ga.joins++
 	player := &Player{
-		ID:    msg.PlayerID,
-		Name:  msg.PlayerName,
-		Score: 0,
-		Ready: false,
-		Conn:  msg.Conn,
+		ID:         msg.PlayerID,
+		Name:       msg.PlayerName,
+		Score:      0,
+		Ready:      false,
+		Token:      msg.Token,
+		Protocol:   msg.ProtocolVersion,
+		RemoteUser: msg.RemoteUser,
+		Role:       RolePlayer,
+		joinOrder:  ga.joins,
+		Conn:       msg.Conn,
+	}
+	if msg.Conn != nil {
+		player.out = NewOutbox(msg.Conn, ga.outbox)
 	}
-	ga.players[msg.PlayerID] = player
 
-	log.Printf("Player %s (%s) joined game %s", msg.PlayerName, msg.PlayerID, ga.id)
+	if msg.Spectate {
+		player.Role = RoleSpectator
+		ga.spectators[msg.PlayerID] = player
+		log.Printf("Spectator %s (%s) is watching game %s", msg.PlayerName, msg.PlayerID, ga.id)
+	} else {
+		ga.players[msg.PlayerID] = player
+		log.Printf("Player %s (%s) joined game %s", msg.PlayerName, msg.PlayerID, ga.id)
+		ga.claimHost(player)
+		if ga.teams != nil {
+			ga.teams.Assign(player.ID, ga.teams.Smallest())
+		}
+	}
+	ga.sendSession(player)
 	ga.broadcastState()
+	return nil
+}
+
+// member finds a player or spectator by ID
+func (ga *GameActor) member(id string) (*Player, bool) {
+	if player, exists := ga.players[id]; exists {
+		return player, true
+	}
+	spectator, exists := ga.spectators[id]
+	return spectator, exists
+}
+
+// everyone returns every connection's owner: players, then spectators
+func (ga *GameActor) everyone() []*Player {
+	all := make([]*Player, 0, len(ga.players)+len(ga.spectators))
+	for _, p := range ga.players {
+		all = append(all, p)
+	}
+	for _, s := range ga.spectators {
+		all = append(all, s)
+	}
+	return all
 }
 
 func (ga *GameActor) handlePlayerLeave(msg PlayerLeaveMsg) {
 	ga.mu.Lock()
 	defer ga.mu.Unlock()
 
-	if player, exists := ga.players[msg.PlayerID]; exists {
-		player.mu.Lock()
-		if player.Conn != nil {
-			player.Conn.Close()
-		}
-		player.mu.Unlock()
-		delete(ga.players, msg.PlayerID)
+	if _, exists := ga.member(msg.PlayerID); exists {
+		ga.removePlayer(msg.PlayerID)
 		log.Printf("Player %s left game %s", msg.PlayerID, ga.id)
 		ga.broadcastState()
 	}
 }
 
-func (ga *GameActor) handleNextGame(msg NextGameMsg) {
+func (ga *GameActor) handlePlayerDisconnect(msg PlayerDisconnectMsg) {
 	ga.mu.Lock()
 	defer ga.mu.Unlock()
 
-	switch ga.state {
-	case "lobby", "":
-		// Pick a random game appropriate for player count and move to instructions
-		ga.state = "instructions"
-		ga.currentGame = RandomGameTypeForPlayers(len(ga.players))
-		ga.broadcastState()
-
-	case "instructions":
-		// Mark player as ready
-		if player, exists := ga.players[msg.PlayerID]; exists {
-			player.Ready = true
-			log.Printf("Player %s marked ready in game %s", player.Name, ga.id)
-		}
-
-		// Check if all players are ready
-		allReady := true
-		readyCount := 0
-		for _, p := range ga.players {
-			if p.Ready {
-				readyCount++
-			} else {
-				allReady = false
-			}
+	if spectator, exists := ga.spectators[msg.PlayerID]; exists {
+		if spectator.Conn == msg.Conn || ga.replaying {
+			ga.events.append(msg)
+			ga.removePlayer(msg.PlayerID)
+			ga.broadcastState()
 		}
-		log.Printf("Ready check: %d/%d players ready in game %s", readyCount, len(ga.players), ga.id)
+		return
+	}
 
-		if allReady && len(ga.players) > 0 {
-			log.Printf("All players ready! Starting %s in game %s", ga.currentGame, ga.id)
-			ga.state = "playing"
-			ga.game = CreateGame(ga.currentGame)
+	player, exists := ga.players[msg.PlayerID]
+	if !exists {
+		return
+	}
 
-			// Set number of players for Claude's Game
-			if cg, ok := ga.game.(*ClaudesGame); ok {
-				cg.numPlayers = len(ga.players)
-			}
+	// A resumed player may still get a late disconnect from their old
+	// socket. Replays have no sockets, but only real disconnects are logged.
+	if player.Conn != msg.Conn && !ga.replaying {
+		return
+	}
+	ga.events.append(msg)
 
-			// Set random actor for games that need an actor
-			ga.assignRandomActor()
+	player.mu.Lock()
+	player.Conn = nil
+	if player.out != nil {
+		player.out.Close()
+		player.out = nil
+	}
+	player.mu.Unlock()
+	player.Disconnected = true
+	player.disconnects++
+
+	// Only the host can move the room on, so don't leave it waiting out
+	// the grace period
+	if player.ID == ga.hostID {
+		ga.pickNewHost()
+		if ga.hostID != player.ID {
+			ga.awayHost = player.ID
+		}
+	}
+	ga.closeVotingIfAllVoted()
 
-			// Start timer for timed games when transitioning from instructions to playing
-			ga.activateTimerIfNeeded()
+	if !ga.replaying {
+		timeout := DisconnectTimeoutMsg{PlayerID: player.ID, Disconnects: player.disconnects}
+		time.AfterFunc(ga.disconnectGrace, func() {
+			ga.Send(timeout)
+		})
+	}
 
-			// Reset ready status
-			for _, p := range ga.players {
-				p.Ready = false
-			}
-		}
-		ga.broadcastState()
+	log.Printf("Player %s disconnected from game %s, holding for %s", player.ID, ga.id, ga.disconnectGrace)
+	ga.broadcastState()
+}
 
-	case "playing":
-		// This shouldn't be called during playing - words are submitted via SubmitWordMsg
-		ga.broadcastState()
+// handlePlayerResume returns the resumed player's ID, or "" if the token
+// is unknown
+func (ga *GameActor) handlePlayerResume(msg PlayerResumeMsg) string {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
 
-	case "finished":
-		// Pick next random game appropriate for player count and move to instructions
-		log.Printf("Game finished in %s, picking next game", ga.id)
-		ga.state = "instructions"
-		ga.currentGame = RandomGameTypeForPlayers(len(ga.players))
-		log.Printf("Next game will be: %s", ga.currentGame)
-		ga.game = nil
-		ga.winners = nil
+	var player *Player
+	if msg.Token != "" {
 		for _, p := range ga.players {
-			p.Ready = false
+			if p.Token == msg.Token {
+				player = p
+				break
+			}
 		}
-		ga.broadcastState()
 	}
+	if player == nil || ga.bans[player.Token] {
+		return ""
+	}
+
+	player.mu.Lock()
+	if player.out != nil {
+		// The old socket hasn't noticed it's dead yet; its pump closes it
+		player.out.Close()
+		player.out = nil
+	}
+	player.Conn = msg.Conn
+	if msg.Conn != nil {
+		player.out = NewOutbox(msg.Conn, ga.outbox)
+	}
+	player.lastState = nil // new connection starts from a snapshot
+	player.mu.Unlock()
+	player.Disconnected = false
+	if msg.ProtocolVersion != 0 {
+		player.Protocol = msg.ProtocolVersion
+	}
+	if player.ID == ga.awayHost {
+		ga.hostID = player.ID
+		ga.awayHost = ""
+		log.Printf("Player %s is host of game %s again", player.ID, ga.id)
+	}
+
+	log.Printf("Player %s resumed in game %s", player.ID, ga.id)
+	ga.sendSession(player)
+	ga.broadcastState()
+	return player.ID
 }
 
-func (ga *GameActor) handleRequestPrompt(msg RequestPromptMsg) {
+func (ga *GameActor) handleDisconnectTimeout(msg DisconnectTimeoutMsg) {
 	ga.mu.Lock()
 	defer ga.mu.Unlock()
 
-	// Only handle for Mad Libs during playing state
-	if ga.state != "playing" || ga.currentGame != "madlibs" || ga.game == nil {
+	player, exists := ga.players[msg.PlayerID]
+	if !exists || !player.Disconnected || player.disconnects != msg.Disconnects {
 		return
 	}
 
-	// Claim a slot for this player
-	if madlib, ok := ga.game.(*MadLib); ok {
-		madlib.ClaimSlotForPlayer(msg.PlayerID)
-		// Broadcast state so player gets their personalized prompt
-		ga.broadcastState()
-	}
+	ga.removePlayer(msg.PlayerID)
+	log.Printf("Player %s did not reconnect to game %s, removed", msg.PlayerID, ga.id)
+	ga.broadcastState()
 }
 
-func (ga *GameActor) handleSubmitWord(msg SubmitWordMsg) {
-	ga.mu.Lock()
-	defer ga.mu.Unlock()
+// removePlayer closes a player's or spectator's connection and drops them
+// from the room
+func (ga *GameActor) removePlayer(playerID string) {
+	player, exists := ga.member(playerID)
+	if !exists {
+		return
+	}
 
-	if ga.state != "playing" || ga.game == nil {
+	player.mu.Lock()
+	if player.out != nil {
+		player.out.Close()
+		player.out = nil
+	}
+	player.mu.Unlock()
+	if player.Role == RoleSpectator {
+		delete(ga.spectators, playerID)
 		return
 	}
+	delete(ga.players, playerID)
+	delete(ga.votes, playerID)
+	if ga.teams != nil {
+		ga.teams.Remove(playerID)
+	}
+	// Free the slot they were filling in, or the story could never finish
+	if slots, ok := ga.game.(SlotGame); ok && ga.state == "playing" {
+		slots.ReleaseSlotsForPlayer(playerID)
+	}
 
-	// Submit word/answer to current game
-	var isComplete bool
-	// For Mad Libs, use the per-player method
-	if madlib, ok := ga.game.(*MadLib); ok {
-		isComplete = madlib.AddWordForPlayer(msg.PlayerID, msg.Word)
-	} else {
-		isComplete = ga.game.SubmitAnswer(msg.PlayerID, msg.Word)
+	if playerID == ga.awayHost {
+		ga.awayHost = ""
 	}
+	if playerID == ga.hostID {
+		ga.pickNewHost()
+	}
+	ga.closeVotingIfAllVoted()
+}
 
-	// Award points based on game type
-	if msg.Word != "timer_complete" && msg.Word != "video_complete" {
-		// For Imitations, only award points to the winner
-		if im, ok := ga.game.(*Imitations); ok {
-			if isComplete && im.GetWinner() == msg.PlayerID {
-				if player, exists := ga.players[msg.PlayerID]; exists {
-					player.Score += 3 // Award 3 points for guessing correctly
-					im.SetWinnerName(player.Name)
-				}
-			}
-		} else if ch, ok := ga.game.(*Charades); ok {
-			// For Charades, only award points to the winner
-			if isComplete && ch.GetWinner() == msg.PlayerID {
-				if player, exists := ga.players[msg.PlayerID]; exists {
-					player.Score += 3 // Award 3 points for guessing correctly
-					ch.SetWinnerName(player.Name)
-				}
-			}
-		} else {
-			// For other games, award 1 point per submission
-			if player, exists := ga.players[msg.PlayerID]; exists {
-				player.Score++
-			}
+// claimHost makes player the host if the room has none, or if they're an
+// authenticated user and the current host is a guest
+func (ga *GameActor) claimHost(player *Player) {
+	host, exists := ga.players[ga.hostID]
+	if !exists || (player.RemoteUser != "" && host.RemoteUser == "") {
+		ga.hostID = player.ID
+		ga.awayHost = ""
+		log.Printf("Player %s is now host of game %s", player.ID, ga.id)
+	}
+}
+
+// pickNewHost hands the room to the next player after the host leaves or
+// drops: connected before disconnected, authenticated before guests, then
+// whoever joined first
+func (ga *GameActor) pickNewHost() {
+	var next *Player
+	for _, p := range ga.players {
+		if next == nil || betterHost(p, next) {
+			next = p
 		}
 	}
 
-	if isComplete {
-		// Games that need voting: youlaughyoulose, firsttofind, blankestblank, claudesgame
-		needsVoting := ga.currentGame == "youlaughyoulose" ||
-			ga.currentGame == "firsttofind" ||
-			ga.currentGame == "blankestblank" ||
-			ga.currentGame == "claudesgame"
+	ga.hostID = ""
+	if next != nil {
+		ga.hostID = next.ID
+		log.Printf("Host of game %s passed to %s", ga.id, next.ID)
+	}
+}
 
-		if needsVoting {
-			ga.state = "voting"
-			ga.votes = make(map[string]string)
-		} else {
-			ga.state = "finished"
-		}
+func betterHost(a, b *Player) bool {
+	if a.Disconnected != b.Disconnected {
+		return !a.Disconnected
 	}
+	if (a.RemoteUser != "") != (b.RemoteUser != "") {
+		return a.RemoteUser != ""
+	}
+	return a.joinOrder < b.joinOrder
+}
 
-	ga.broadcastState()
+// isBanned checks a joining player against the room's bans
+func (ga *GameActor) isBanned(token, remoteUser, name string) bool {
+	return (token != "" && ga.bans[token]) ||
+		(remoteUser != "" && ga.bans["user:"+remoteUser]) ||
+		ga.bans["name:"+strings.ToLower(strings.TrimSpace(name))]
 }
 
-func (ga *GameActor) handleVote(msg VoteMsg) {
+// requireHost reports an error to the player unless they're the host
+func (ga *GameActor) requireHost(playerID, action string) bool {
+	if playerID == ga.hostID {
+		return true
+	}
+	if player, exists := ga.players[playerID]; exists {
+		ga.sendToPlayer(player, NewErrorEvent(ErrCodeNotHost, "Only the host can "+action))
+	}
+	return false
+}
+
+func (ga *GameActor) handleKickPlayer(msg KickPlayerMsg) {
 	ga.mu.Lock()
 	defer ga.mu.Unlock()
 
-	if ga.state != "voting" {
-		log.Printf("Vote received but state is %s, not voting", ga.state)
+	if !ga.requireHost(msg.PlayerID, "remove players") {
+		return
+	}
+	target, exists := ga.member(msg.TargetID)
+	if !exists || msg.TargetID == msg.PlayerID {
+		ga.sendToPlayer(ga.players[msg.PlayerID], NewErrorEvent(ErrCodeUnknownPlayer, "No such player to remove"))
 		return
 	}
 
-	log.Printf("Vote received: %s voted for %s", msg.PlayerID, msg.VotedForID)
-
-	// Record vote
-	ga.votes[msg.PlayerID] = msg.VotedForID
-
-	log.Printf("Votes so far: %d/%d", len(ga.votes), len(ga.players))
-
-	// Check if all players have voted
-	if len(ga.votes) >= len(ga.players) {
-		log.Printf("All players have voted! Counting votes...")
-		// Count votes
-		voteCounts := make(map[string]int)
-		for _, votedFor := range ga.votes {
-			voteCounts[votedFor]++
-		}
-
-		// Find winner(s)
-		maxVotes := 0
-		for _, count := range voteCounts {
-			if count > maxVotes {
-				maxVotes = count
-			}
-		}
-
-		// Award points to winner(s)
-		ga.winners = []string{}
-		for playerID, count := range voteCounts {
-			if count == maxVotes {
-				if player, exists := ga.players[playerID]; exists {
-					player.Score += 3
-					ga.winners = append(ga.winners, player.Name)
-				}
-			}
+	if msg.Ban {
+		ga.bans[target.Token] = true
+		if target.RemoteUser != "" {
+			ga.bans["user:"+target.RemoteUser] = true
 		}
+		ga.bans["name:"+strings.ToLower(strings.TrimSpace(target.Name))] = true
+	}
 
-		ga.state = "finished"
-		ga.broadcastState()
-	} else {
-		ga.broadcastState()
+	// Let them know, then hang up once the notice is written
+	ga.sendToPlayer(target, KickedEvent{Action: "kicked", Banned: msg.Ban})
+	target.mu.Lock()
+	if target.out != nil {
+		target.out.CloseAfterFlush()
+		target.out = nil
 	}
+	target.mu.Unlock()
+
+	ga.removePlayer(target.ID)
+	log.Printf("Player %s removed from game %s by host (ban: %v)", target.ID, ga.id, msg.Ban)
+	ga.broadcastState()
 }
 
-func (ga *GameActor) handlePing(msg PingMsg) {
-	ga.mu.RLock()
-	defer ga.mu.RUnlock()
+func (ga *GameActor) handleSkipGame(msg SkipGameMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
 
-	if player, exists := ga.players[msg.PlayerID]; exists {
-		player.mu.Lock()
-		defer player.mu.Unlock()
+	if !ga.requireHost(msg.PlayerID, "skip games") || ga.state == "lobby" || ga.state == "" || ga.state == "podium" {
+		return
+	}
 
-		pong := map[string]interface{}{
-			"action": "pong",
-		}
-		if player.Conn != nil {
-			player.Conn.WriteJSON(pong)
-		}
+	log.Printf("Host skipped %s in game %s", ga.currentGame, ga.id)
+	if !ga.startNextGame(msg.PlayerID, "") {
+		// Nothing to pick automatically, so the host chooses from the lobby
+		ga.resetToLobby()
 	}
+	ga.broadcastState()
 }
 
-func (ga *GameActor) handleGetGameState(msg GetGameStateMsg) {
-	ga.mu.RLock()
-	defer ga.mu.RUnlock()
-
-	state := &GameState{
-		ID:          ga.id,
-		State:       ga.state,
-		CurrentGame: ga.currentGame,
-		Players:     make(map[string]*PlayerInfo),
-	}
+func (ga *GameActor) handleLockRoom(msg LockRoomMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
 
-	for id, p := range ga.players {
-		state.Players[id] = &PlayerInfo{
-			ID:    p.ID,
-			Name:  p.Name,
-			Score: p.Score,
-			Ready: p.Ready,
-		}
+	if !ga.requireHost(msg.PlayerID, "lock the room") {
+		return
 	}
 
-	msg.ResponseChan <- state
+	ga.locked = msg.Locked
+	log.Printf("Game %s locked: %v", ga.id, ga.locked)
+	ga.broadcastState()
 }
 
-func (ga *GameActor) broadcastState() {
-	// Build state message
-	playersList := make([]map[string]interface{}, 0, len(ga.players))
+// connectedCount returns the number of players with a live connection
+func (ga *GameActor) connectedCount() int {
+	count := 0
 	for _, p := range ga.players {
-		playersList = append(playersList, map[string]interface{}{
-			"id":    p.ID,
-			"name":  p.Name,
-			"score": p.Score,
-			"ready": p.Ready,
-		})
+		if !p.Disconnected {
+			count++
+		}
 	}
+	return count
+}
 
-	var gameTitle, gameInstructions, roundInstructions string
-	numPlayers := len(ga.players)
+func (ga *GameActor) handleNextGame(msg NextGameMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
 
 	switch ga.state {
 	case "lobby", "":
-		if numPlayers == 0 {
-			gameTitle = "Waiting for players to join"
-			gameInstructions = "Wait for players to join"
-			roundInstructions = "Share this URL with friends to play together!"
-		} else if numPlayers == 1 {
-			gameTitle = "Ready to play!"
-			gameInstructions = "Click 'Next' to start a game"
-			roundInstructions = "Most games work solo! Or share the URL to play with friends."
-		} else {
-			gameTitle = "Waiting for more players"
-			gameInstructions = "Wait for more players or click 'Next' to start"
-			roundInstructions = "Ready to play?"
+		// Pick a random game appropriate for player count and move to instructions
+		if !ga.requireHost(msg.PlayerID, "start a game") {
+			return
+		}
+		if ga.startNextGame(msg.PlayerID, msg.Game) {
+			ga.broadcastState()
 		}
 
 	case "instructions":
-		if ga.currentGame != "" {
-			tempGame := CreateGame(ga.currentGame)
-			// For BlankestBlank, use the prompt as the title instead of the generic game name
-			if ga.currentGame == "blankestblank" {
-				gameTitle = tempGame.GetPrompt()
-				gameInstructions = tempGame.GetInstructions()
-				// BlankestBlank instructions already say "Click next when everyone has their [item]"
-				// so we don't need redundant round instructions
-				roundInstructions = ""
-			} else {
-				gameTitle = tempGame.GetName()
-				gameInstructions = tempGame.GetInstructions()
-				if numPlayers == 1 {
-					roundInstructions = "Click 'Next' when ready to play"
-				} else {
-					roundInstructions = "Everyone click 'Next' when ready"
-				}
-			}
-		} else {
-			gameTitle = "Get Ready!"
-			gameInstructions = "Prepare for the next game"
-			if numPlayers == 1 {
-				roundInstructions = "Click 'Next' when ready to play"
-			} else {
-				roundInstructions = "Everyone click 'Next' when ready"
-			}
+		// Mark player as ready
+		if player, exists := ga.players[msg.PlayerID]; exists {
+			player.Ready = true
+			log.Printf("Player %s marked ready in game %s", player.Name, ga.id)
 		}
 
-	case "playing":
-		if ga.game != nil {
-			// Use the prompt as the big title text
-			gameTitle = ga.game.GetPrompt()
-			if ga.game.NeedsInput() {
-				gameInstructions = "Enter your answer:"
-				roundInstructions = ""
+		// Check if all connected players are ready
+		allReady := true
+		readyCount := 0
+		for _, p := range ga.players {
+			if p.Disconnected {
+				continue
+			}
+			if p.Ready {
+				readyCount++
 			} else {
-				gameInstructions = ""
-				roundInstructions = ""
+				allReady = false
 			}
-		} else {
-			gameTitle = "Playing..."
-			gameInstructions = "Loading..."
-			roundInstructions = ""
 		}
+		log.Printf("Ready check: %d/%d players ready in game %s", readyCount, ga.connectedCount(), ga.id)
 
-	case "voting":
-		gameTitle = "Time to Vote!"
-		if ga.game != nil {
-			gameInstructions = ga.game.GetResult()
-			roundInstructions = "" // GetResult() already contains voting instructions
-		} else {
-			gameInstructions = "Who kept the straightest face?"
-			roundInstructions = ""
+		if allReady && readyCount > 0 {
+			log.Printf("All players ready! Starting %s in game %s", ga.currentGame, ga.id)
+			ga.startRound()
 		}
+		ga.broadcastState()
+
+	case "playing":
+		// This shouldn't be called during playing - words are submitted via SubmitWordMsg
+		ga.broadcastState()
+
+	case "podium":
+		// The match is over; only a rematch starts another
+		if player, exists := ga.players[msg.PlayerID]; exists {
+			ga.sendToPlayer(player, NewErrorEvent(ErrCodeNoGame, "The match is over, start a rematch to play again"))
+		}
+
+	case "finished":
+		// Play another round of the same game if the room's rules call for
+		// one, otherwise pick the next game and move to instructions
+		if !ga.requireHost(msg.PlayerID, "start the next game") {
+			return
+		}
+		if ga.matchOver() {
+			log.Printf("Match over in game %s after %d games", ga.id, ga.gamesPlayed)
+			ga.state = "podium"
+			ga.broadcastState()
+			return
+		}
+		if msg.Game == "" && ga.currentGame != "" && ga.round < ga.settings.Rounds {
+			ga.round++
+			log.Printf("Starting round %d of %s in game %s", ga.round, ga.currentGame, ga.id)
+			ga.startRound()
+			ga.broadcastState()
+			return
+		}
+		log.Printf("Game finished in %s, picking next game", ga.id)
+		if ga.startNextGame(msg.PlayerID, msg.Game) {
+			log.Printf("Next game will be: %s", ga.currentGame)
+			ga.broadcastState()
+		}
+	}
+}
+
+// startNextGame drops any game in progress and moves to the instructions
+// for the next one: the host's pick if given, otherwise the selector's. If
+// there's nothing to play it tells the player why and returns false.
+func (ga *GameActor) startNextGame(playerID, pick string) bool {
+	next, perr := ga.chooseNextGame(pick)
+	if perr != nil {
+		if player, exists := ga.players[playerID]; exists {
+			ga.sendToPlayer(player, perr.Event())
+		}
+		return false
+	}
+
+	prev := ga.currentGame
+	ga.currentGame = next
+	if !ga.prepareGame() {
+		ga.currentGame = prev
+		if player, exists := ga.players[playerID]; exists {
+			ga.sendToPlayer(player, NewErrorEvent(ErrCodeNoGame, fmt.Sprintf("%q isn't a game", next)))
+		}
+		return false
+	}
+
+	ga.stopTimer()
+	ga.state = "instructions"
+	ga.round = 1
+	ga.game = nil
+	ga.votes = nil
+	ga.runoff = nil
+	ga.voteResults = nil
+	ga.winners = nil
+	ga.actingTeam = 0
+	for _, p := range ga.players {
+		p.Ready = false
+	}
+	return true
+}
+
+// startRound creates a fresh game of the current type and starts playing it
+func (ga *GameActor) startRound() {
+	if ga.pending == nil && !ga.prepareGame() {
+		return
+	}
+	ga.state = "playing"
+	ga.game = ga.pending
+	ga.pending = nil
+	ga.votes = nil
+	ga.runoff = nil
+	ga.voteResults = nil
+	ga.winners = nil
+	ga.actingTeam = 0
+	ga.roundStartScores = make(map[string]int, len(ga.players))
+	for id, p := range ga.players {
+		ga.roundStartScores[id] = p.Score
+	}
+
+	// Tell games like Claude's Game how many answers to wait for
+	if pc, ok := ga.game.(PlayerCountGame); ok {
+		pc.SetNumPlayers(ga.connectedCount())
+	}
+
+	// Set random actor for games that need an actor
+	ga.assignRandomActor()
+
+	// Start timer for timed games
+	ga.activateTimerIfNeeded()
+
+	// Reset ready status
+	for _, p := range ga.players {
+		p.Ready = false
+	}
+}
+
+// prepareGame creates the next round's game from a fresh round seed, so the
+// instructions describe the game that will actually be played. Returns false
+// if the current game isn't registered.
+func (ga *GameActor) prepareGame() bool {
+	desc, ok := LookupGame(ga.currentGame)
+	if !ok {
+		log.Printf("Game %s can't prepare unknown game %q", ga.id, ga.currentGame)
+		return false
+	}
+	config := ga.settings.GameConfig(desc)
+	config.Content = ga.roundContent()
+	ga.roundSeed = ga.rng.Int63()
+	config.Rand = rand.New(rand.NewSource(ga.roundSeed))
+	ga.pending = CreateGame(ga.currentGame, config)
+	return ga.pending != nil
+}
+
+// roundContent resolves the room's packs and templates for a new round and
+// logs them. A replay takes them from the log it's replaying instead, so
+// pack edits since don't change its picks. Logs from before rounds were
+// logged fall back to the packs loaded now.
+func (ga *GameActor) roundContent() *Content {
+	var content *Content
+	if ga.replayOf != nil {
+		content = ga.replayOf.roundContent(len(ga.events.Rounds))
+	}
+	if content == nil {
+		content = contentPacks.Content(ga.packs)
+		if len(ga.templates) > 0 {
+			content.MadLibTemplates = ga.templates
+		}
+	}
+	ga.events.appendRound(content)
+	return content
+}
+
+// chooseNextGame validates the host's pick or asks the selector
+func (ga *GameActor) chooseNextGame(pick string) (string, *ProtocolError) {
+	playerCount := len(ga.players)
+
+	if pick != "" {
+		if !ga.selector.Allowed(pick, playerCount) {
+			return "", &ProtocolError{Code: ErrCodeNoGame, Message: fmt.Sprintf("%q can't be played in this room right now", pick)}
+		}
+		ga.selector.Played(pick)
+		return pick, nil
+	}
+
+	if ga.selector.Mode == SelectHostPicks {
+		return "", &ProtocolError{Code: ErrCodeNoGame, Message: "Pick the next game"}
+	}
+	if next := ga.selector.Next(playerCount); next != "" {
+		return next, nil
+	}
+	return "", &ProtocolError{Code: ErrCodeNoGame, Message: fmt.Sprintf("No enabled game works with %d players", playerCount)}
+}
+
+// betweenGames reports an error to the player unless the room is in the
+// lobby, has just finished a game or is on the podium
+func (ga *GameActor) betweenGames(playerID string) bool {
+	if ga.isBetweenGames() {
+		return true
+	}
+	if player, exists := ga.players[playerID]; exists {
+		ga.sendToPlayer(player, NewErrorEvent(ErrCodeBadSettings, "Game settings can only be changed between games"))
+	}
+	return false
+}
+
+func (ga *GameActor) isBetweenGames() bool {
+	return ga.state == "lobby" || ga.state == "" || ga.state == "finished" || ga.state == "podium"
+}
+
+func (ga *GameActor) handleSetSelection(msg SetSelectionMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	if !ga.requireHost(msg.PlayerID, "change how games are picked") || !ga.betweenGames(msg.PlayerID) {
+		return
+	}
+	if err := ga.selector.SetMode(msg.Mode, msg.Playlist); err != nil {
+		ga.sendToPlayer(ga.players[msg.PlayerID], NewErrorEvent(ErrCodeBadSettings, err.Error()))
+		return
+	}
+
+	log.Printf("Game %s now picks games by %s", ga.id, msg.Mode)
+	ga.broadcastState()
+}
+
+func (ga *GameActor) handleEnableGame(msg EnableGameMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	if !ga.requireHost(msg.PlayerID, "change which games are played") || !ga.betweenGames(msg.PlayerID) {
+		return
+	}
+	if err := ga.selector.SetEnabled(msg.Game, msg.Enabled); err != nil {
+		ga.sendToPlayer(ga.players[msg.PlayerID], NewErrorEvent(ErrCodeBadSettings, err.Error()))
+		return
+	}
+
+	ga.broadcastState()
+}
+
+func (ga *GameActor) handleUpdateSettings(msg UpdateSettingsMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	if !ga.requireHost(msg.PlayerID, "change the room's rules") || !ga.betweenGames(msg.PlayerID) {
+		return
+	}
+	settings := ga.settings
+	if err := json.Unmarshal(msg.Changes, &settings); err != nil {
+		ga.sendToPlayer(ga.players[msg.PlayerID], NewErrorEvent(ErrCodeBadSettings, "settings: "+err.Error()))
+		return
+	}
+	if err := settings.Validate(); err != nil {
+		ga.sendToPlayer(ga.players[msg.PlayerID], NewErrorEvent(ErrCodeBadSettings, err.Error()))
+		return
+	}
+
+	ga.settings = settings
+	log.Printf("Game %s settings changed to %+v", ga.id, ga.settings)
+	ga.broadcastState()
+}
+
+// handleSetTeams turns team play on, dealing players into even teams, or
+// off. Turning it on again starts the teams afresh.
+func (ga *GameActor) handleSetTeams(msg SetTeamsMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	if !ga.requireHost(msg.PlayerID, "set up teams") || !ga.betweenGames(msg.PlayerID) {
+		return
+	}
+	if msg.Count == 0 {
+		ga.teams = nil
+		log.Printf("Game %s turned team play off", ga.id)
+		ga.broadcastState()
+		return
+	}
+
+	teams, err := NewTeams(msg.Count)
+	if err != nil {
+		ga.sendToPlayer(ga.players[msg.PlayerID], NewErrorEvent(ErrCodeBadSettings, err.Error()))
+		return
+	}
+	teams.Balance(ga.playerIDs(), ga.rng)
+	ga.teams = teams
+	log.Printf("Game %s split into %d teams", ga.id, msg.Count)
+	ga.broadcastState()
+}
+
+func (ga *GameActor) handleAssignTeam(msg AssignTeamMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	if !ga.requireHost(msg.PlayerID, "assign teams") || !ga.requireTeams(msg.PlayerID) || !ga.betweenGames(msg.PlayerID) {
+		return
+	}
+	if _, exists := ga.players[msg.TargetID]; !exists {
+		ga.sendToPlayer(ga.players[msg.PlayerID], NewErrorEvent(ErrCodeUnknownPlayer, "No such player to assign"))
+		return
+	}
+	if err := ga.teams.Assign(msg.TargetID, msg.Team); err != nil {
+		ga.sendToPlayer(ga.players[msg.PlayerID], NewErrorEvent(ErrCodeBadSettings, err.Error()))
+		return
+	}
+
+	ga.broadcastState()
+}
+
+func (ga *GameActor) handleBalanceTeams(msg BalanceTeamsMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	if !ga.requireHost(msg.PlayerID, "balance teams") || !ga.requireTeams(msg.PlayerID) || !ga.betweenGames(msg.PlayerID) {
+		return
+	}
+	ga.teams.Balance(ga.playerIDs(), ga.rng)
+	ga.broadcastState()
+}
+
+// requireTeams reports an error to the player unless team play is on
+func (ga *GameActor) requireTeams(playerID string) bool {
+	if ga.teams != nil {
+		return true
+	}
+	if player, exists := ga.players[playerID]; exists {
+		ga.sendToPlayer(player, NewErrorEvent(ErrCodeBadSettings, "Team play is off"))
+	}
+	return false
+}
+
+// playerIDs returns every player's ID, sorted
+func (ga *GameActor) playerIDs() []string {
+	ids := make([]string, 0, len(ga.players))
+	for id := range ga.players {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// award gives a player points, and their team too in team play
+func (ga *GameActor) award(player *Player, points int) {
+	player.Score += points
+	if ga.teams != nil {
+		ga.teams.AddPoints(player.ID, points)
+	}
+}
+
+func (ga *GameActor) handleSetPacks(msg SetPacksMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	if !ga.requireHost(msg.PlayerID, "choose content packs") || !ga.betweenGames(msg.PlayerID) {
+		return
+	}
+	for _, name := range msg.Packs {
+		if !contentPacks.Has(name) {
+			ga.sendToPlayer(ga.players[msg.PlayerID], NewErrorEvent(ErrCodeBadSettings, fmt.Sprintf("No content pack named %q", name)))
+			return
+		}
+	}
+
+	ga.packs = append([]string(nil), msg.Packs...)
+	log.Printf("Game %s now uses content packs %v", ga.id, ga.packs)
+	ga.broadcastState()
+}
+
+// maxRoomTemplates caps the templates a room can add
+const maxRoomTemplates = 20
+
+func (ga *GameActor) handleAddTemplate(msg AddTemplateMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	player, isPlayer := ga.players[msg.PlayerID]
+	if !isPlayer {
+		return
+	}
+	template := msg.Template
+	if err := template.Normalize(); err != nil {
+		ga.sendToPlayer(player, NewErrorEvent(ErrCodeBadTemplate, err.Error()))
+		return
+	}
+	if len(ga.templates) >= maxRoomTemplates {
+		ga.sendToPlayer(player, NewErrorEvent(ErrCodeBadTemplate, fmt.Sprintf("This room already has %d templates", maxRoomTemplates)))
+		return
+	}
+
+	ga.templates = append(ga.templates, template)
+	log.Printf("%s added a Mad Libs template to game %s", player.Name, ga.id)
+	ga.broadcastState()
+}
+
+func (ga *GameActor) handleClearTemplates(msg ClearTemplatesMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	if !ga.requireHost(msg.PlayerID, "remove the room's templates") {
+		return
+	}
+	ga.templates = nil
+	ga.broadcastState()
+}
+
+func (ga *GameActor) handleRequestPrompt(msg RequestPromptMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	// Only handle for slot games (Mad Libs) during playing state
+	if _, isPlayer := ga.players[msg.PlayerID]; !isPlayer || ga.state != "playing" || ga.game == nil {
+		return
+	}
+
+	// Claim a slot for this player
+	if slots, ok := ga.game.(SlotGame); ok {
+		slots.ClaimSlotForPlayer(msg.PlayerID)
+		// Broadcast state so player gets their personalized prompt
+		ga.broadcastState()
+	}
+}
+
+func (ga *GameActor) handleSubmitWord(msg SubmitWordMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	if _, isPlayer := ga.players[msg.PlayerID]; !isPlayer || ga.state != "playing" || ga.game == nil {
+		return
+	}
+
+	// Timed rounds are ended by the server's ticker, never by a client, but
+	// some, like Trivia, take answers while the clock runs
+	if ga.game.HasTimer() && !ga.game.NeedsInput() {
+		return
+	}
+
+	// In team play only the acting team's guesses count
+	if ga.actingTeam != 0 && ga.teams.TeamOf(msg.PlayerID) != ga.actingTeam {
+		return
+	}
+
+	// Submit word/answer to current game
+	accepted, isComplete := true, false
+	if ag, ok := ga.game.(AcceptingGame); ok {
+		accepted, isComplete = ag.Submit(msg.PlayerID, msg.Word)
+	} else {
+		isComplete = ga.game.SubmitAnswer(msg.PlayerID, msg.Word)
+	}
+
+	// Award points based on the game's scoring policy
+	desc, _ := LookupGame(ga.currentGame)
+	if player, exists := ga.players[msg.PlayerID]; exists {
+		switch desc.Scoring {
+		case ScoreWinner:
+			// Only the player who completed the round scores
+			if wg, ok := ga.game.(WinnerGame); ok && isComplete && wg.GetWinner() == msg.PlayerID {
+				ga.award(player, ga.settings.GuessPoints)
+				wg.SetWinnerName(player.Name)
+			}
+		case ScorePerSubmission:
+			if accepted {
+				ga.award(player, ga.settings.SubmissionPoints)
+			}
+		}
+	}
+	ga.collectPoints()
+
+	if isComplete && !ga.game.HasTimer() {
+		ga.endRound()
+	}
+
+	ga.broadcastState()
+}
+
+// handleTimerTick advances the round timer by one tick and ends the round
+// when it runs out
+func (ga *GameActor) handleTimerTick(msg TimerTickMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	// The same ticker runs the voting deadline
+	if ga.state == "voting" && ga.voteRemaining > 0 {
+		ga.voteRemaining--
+		if ga.voteRemaining == 0 {
+			log.Printf("Voting deadline passed in game %s", ga.id)
+			ga.closeVoting()
+		}
+		ga.broadcastState()
+		return
+	}
+
+	// Slot games use the ticker for claim timeouts rather than a round timer
+	if slots, ok := ga.game.(SlotGame); ok && ga.state == "playing" && !ga.game.HasTimer() {
+		if slots.TickClaims() {
+			ga.broadcastState()
+		}
+		return
+	}
+
+	// Ticks can still be queued after the round ended
+	if ga.state != "playing" || ga.game == nil || !ga.game.HasTimer() {
+		ga.stopTimer()
+		return
+	}
+
+	ga.game.DecrementTimer()
+	ga.collectPoints()
+	if ga.game.GetTimeRemaining() <= 0 {
+		log.Printf("Timer expired for %s in game %s", ga.currentGame, ga.id)
+		ga.endRound()
+	}
+
+	ga.broadcastState()
+}
+
+// collectPoints awards the points a game that scores itself has worked out
+func (ga *GameActor) collectPoints() {
+	scored, ok := ga.game.(ScoredGame)
+	if !ok {
+		return
+	}
+	for id, points := range scored.TakePoints() {
+		if player, exists := ga.players[id]; exists {
+			ga.award(player, points)
+		}
+	}
+}
+
+// endRound moves a completed game to voting or straight to finished
+func (ga *GameActor) endRound() {
+	ga.stopTimer()
+
+	if desc, _ := LookupGame(ga.currentGame); desc.NeedsVoting || ga.storyVote() != nil {
+		ga.startVoting()
+	} else {
+		ga.finishGame()
+	}
+}
+
+// finishGame moves to finished, adds the completed game to the match and
+// reports it
+func (ga *GameActor) finishGame() {
+	ga.state = "finished"
+	if ga.round >= ga.settings.Rounds {
+		ga.gamesPlayed++
+	}
+
+	record := ga.gameRecord()
+	// Games that score themselves are won on points
+	if desc, _ := LookupGame(ga.currentGame); desc.Scoring == ScoreByGame && len(ga.winners) == 0 {
+		ga.winners = record.Winners
+	}
+	ga.match = append(ga.match, record)
+	if len(ga.match) > maxMatchSummary {
+		ga.match = ga.match[len(ga.match)-maxMatchSummary:]
+	}
+	if ga.onGameOver != nil {
+		go ga.onGameOver(record)
+	}
+}
+
+// maxMatchSummary caps the rounds kept in the match summary, so an endless
+// match doesn't grow every state update without bound
+const maxMatchSummary = 100
+
+// matchOver reports whether the match has played all its games or someone
+// has reached its point target. In team play the target is for teams.
+func (ga *GameActor) matchOver() bool {
+	if ga.settings.MatchGames > 0 && ga.gamesPlayed >= ga.settings.MatchGames {
+		return true
+	}
+	target := ga.settings.MatchPoints
+	if target == 0 {
+		return false
+	}
+	if ga.teams != nil {
+		for _, score := range ga.teams.Scores {
+			if score >= target {
+				return true
+			}
+		}
+		return false
+	}
+	for _, p := range ga.players {
+		if p.Score >= target {
+			return true
+		}
+	}
+	return false
+}
+
+// standings ranks the players, or the teams in team play, by score. Tied
+// scores share a place.
+func (ga *GameActor) standings() []Standing {
+	var standings []Standing
+	if ga.teams != nil {
+		for _, team := range ga.teams.Data() {
+			standings = append(standings, Standing{Name: team.Name + " team", Score: team.Score})
+		}
+	} else {
+		for _, p := range ga.players {
+			standings = append(standings, Standing{ID: p.ID, Name: p.Name, Score: p.Score})
+		}
+	}
+
+	sort.Slice(standings, func(i, j int) bool {
+		if standings[i].Score != standings[j].Score {
+			return standings[i].Score > standings[j].Score
+		}
+		return standings[i].Name < standings[j].Name
+	})
+	for i := range standings {
+		if i > 0 && standings[i].Score == standings[i-1].Score {
+			standings[i].Place = standings[i-1].Place
+		} else {
+			standings[i].Place = i + 1
+		}
+	}
+	return standings
+}
+
+// handleRematch starts a new match in the same room: scores and the match
+// summary are cleared, while players, teams and settings stay
+func (ga *GameActor) handleRematch(msg RematchMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	if !ga.requireHost(msg.PlayerID, "start a rematch") || !ga.betweenGames(msg.PlayerID) {
+		return
+	}
+
+	ga.resetToLobby()
+	ga.round = 0
+	ga.match = nil
+	ga.gamesPlayed = 0
+	for _, p := range ga.players {
+		p.Score = 0
+	}
+	if ga.teams != nil {
+		ga.teams.ResetScores()
+	}
+	log.Printf("Rematch started in game %s", ga.id)
+	ga.broadcastState()
+}
+
+// gameRecord describes the game that just finished. Vote winners win
+// voting games; otherwise whoever scored the most points in it.
+func (ga *GameActor) gameRecord() GameRecord {
+	record := GameRecord{
+		Room:       ga.id,
+		Game:       ga.currentGame,
+		Round:      ga.round,
+		FinishedAt: time.Now(),
+		Winners:    append([]string{}, ga.winners...),
+	}
+
+	best := 0
+	for id, p := range ga.players {
+		result := PlayerResult{
+			Name:   p.Name,
+			User:   p.RemoteUser,
+			Points: p.Score - ga.roundStartScores[id],
+		}
+		if result.Points > best {
+			best = result.Points
+		}
+		record.Players = append(record.Players, result)
+	}
+	sort.Slice(record.Players, func(i, j int) bool { return record.Players[i].Name < record.Players[j].Name })
+
+	if len(record.Winners) == 0 && best > 0 {
+		for _, result := range record.Players {
+			if result.Points == best {
+				record.Winners = append(record.Winners, result.Name)
+			}
+		}
+	}
+	for i := range record.Players {
+		for _, name := range record.Winners {
+			if record.Players[i].Name == name {
+				record.Players[i].Won = true
+			}
+		}
+	}
+	return record
+}
 
-	case "finished":
-		gameTitle = "Game Complete!"
-		if ga.game != nil {
-			gameInstructions = ga.game.GetName() + " finished!"
-			if len(ga.winners) > 0 {
-				if len(ga.winners) == 1 {
-					// For Mad Libs, don't include GetResult() here since it's shown separately via stateData["story"]
-					if ga.currentGame == "madlibs" {
-						roundInstructions = ga.winners[0] + " wins!"
-					} else {
-						roundInstructions = ga.winners[0] + " wins! " + ga.game.GetResult()
-					}
-				} else {
-					// Tie
-					winnersList := ""
-					for i, w := range ga.winners {
-						if i > 0 {
-							winnersList += ", "
-						}
-						winnersList += w
-					}
-					// For Mad Libs, don't include GetResult() here since it's shown separately via stateData["story"]
-					if ga.currentGame == "madlibs" {
-						roundInstructions = "Tie! " + winnersList + " win!"
-					} else {
-						roundInstructions = "Tie! " + winnersList + " win! " + ga.game.GetResult()
-					}
-				}
-			} else {
-				// For Mad Libs, don't show story in roundInstructions - it's shown via stateData["story"]
-				if ga.currentGame == "madlibs" {
-					roundInstructions = ""
-				} else {
-					roundInstructions = ga.game.GetResult()
-				}
+func (ga *GameActor) handleVote(msg VoteMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	if ga.state != "voting" {
+		log.Printf("Vote received but state is %s, not voting", ga.state)
+		return
+	}
+
+	// Only players vote
+	voter, exists := ga.players[msg.PlayerID]
+	if !exists {
+		return
+	}
+	votedFor := msg.VotedForID
+	if ga.storyVote() != nil {
+		votedFor = msg.Contribution
+	}
+	if msg.Abstain {
+		votedFor = ""
+	} else if perr := ga.checkVote(msg.PlayerID, votedFor); perr != nil {
+		ga.sendToPlayer(voter, perr.Event())
+		return
+	}
+
+	log.Printf("Vote received: %s voted for %q", msg.PlayerID, votedFor)
+	ga.votes[msg.PlayerID] = votedFor
+	log.Printf("Votes so far: %d/%d", len(ga.votes), ga.connectedCount())
+
+	ga.closeVotingIfAllVoted()
+	ga.broadcastState()
+}
+
+// closeVotingIfAllVoted counts the votes once every connected player has
+// voted. Besides each vote, it's checked when a player leaves or drops, or
+// the vote could wait on someone who isn't there.
+func (ga *GameActor) closeVotingIfAllVoted() {
+	if ga.state != "voting" {
+		return
+	}
+	connected, voted := 0, 0
+	for id, p := range ga.players {
+		if p.Disconnected {
+			continue
+		}
+		connected++
+		if _, ok := ga.votes[id]; ok {
+			voted++
+		}
+	}
+	if connected > 0 && voted >= connected {
+		log.Printf("All players have voted! Counting votes...")
+		ga.closeVoting()
+	}
+}
+
+// checkVote returns why a vote isn't allowed, or nil if it is. In a story
+// vote the candidate is a word, and the rules apply to its author.
+func (ga *GameActor) checkVote(voterID, candidateID string) *ProtocolError {
+	authorID := candidateID
+	if story := ga.storyVote(); story != nil {
+		contribution, exists := findContribution(story.Contributions(), candidateID)
+		if !exists {
+			return &ProtocolError{Code: ErrCodeBadVote, Message: "No such word to vote for"}
+		}
+		authorID = contribution.AuthorID
+	} else if _, exists := ga.players[candidateID]; !exists {
+		return &ProtocolError{Code: ErrCodeBadVote, Message: "No such player to vote for"}
+	}
+	if ga.runoff != nil && !containsString(ga.runoff, candidateID) {
+		return &ProtocolError{Code: ErrCodeBadVote, Message: "Vote for one of the tied entries"}
+	}
+	if authorID == voterID && !ga.settings.AllowSelfVotes && len(ga.players) > 1 {
+		return &ProtocolError{Code: ErrCodeBadVote, Message: "You can't vote for yourself"}
+	}
+	if ga.ownTeamVote(voterID, authorID) {
+		return &ProtocolError{Code: ErrCodeBadVote, Message: "You can't vote for your own team"}
+	}
+	return nil
+}
+
+// storyVote returns the game when the room votes on its pieces rather than
+// on players, as it does after a Mad Lib with story voting on
+func (ga *GameActor) storyVote() ContributionGame {
+	if story, ok := ga.game.(ContributionGame); ok && ga.settings.StoryVoting {
+		return story
+	}
+	return nil
+}
+
+func findContribution(contributions []Contribution, id string) (Contribution, bool) {
+	for _, contribution := range contributions {
+		if contribution.ID == id {
+			return contribution, true
+		}
+	}
+	return Contribution{}, false
+}
+
+// candidate returns the player a vote is for, or in a story vote the author
+// of the word and the word itself. The player is nil if they've left.
+func (ga *GameActor) candidate(candidateID string) (*Player, string) {
+	story := ga.storyVote()
+	if story == nil {
+		return ga.players[candidateID], ""
+	}
+	contribution, _ := findContribution(story.Contributions(), candidateID)
+	return ga.players[contribution.AuthorID], contribution.Word
+}
+
+// startVoting opens the vote, with a deadline if the room has one
+func (ga *GameActor) startVoting() {
+	ga.state = "voting"
+	ga.votes = make(map[string]string)
+	ga.voteRemaining = ga.settings.VoteSeconds
+	if ga.voteRemaining > 0 {
+		ga.startTimer()
+	}
+}
+
+// closeVoting counts the votes, settling ties by the room's tie break, and
+// awards the vote points. Players who didn't vote abstained. A runoff opens
+// a new vote instead of finishing the game.
+func (ga *GameActor) closeVoting() {
+	ga.stopTimer()
+	voters := make([]string, 0, len(ga.votes))
+	for id := range ga.votes {
+		voters = append(voters, id)
+	}
+	sort.Strings(voters)
+	for _, id := range voters {
+		result := VoteResult{Voter: ga.players[id].Name, Runoff: ga.runoff != nil}
+		candidate, word := ga.candidate(ga.votes[id])
+		if candidate != nil {
+			result.VotedFor = candidate.Name
+		}
+		result.Word = word
+		ga.voteResults = append(ga.voteResults, result)
+	}
+
+	top := topVoted(ga.votes)
+	points := ga.settings.VotePoints
+	if len(top) > 1 {
+		switch ga.settings.TieBreak {
+		case TieRunoff:
+			if ga.runoff == nil {
+				log.Printf("Tied vote in game %s, holding a runoff between %v", ga.id, top)
+				ga.startVoting()
+				ga.runoff = top
+				return
+			}
+			points /= len(top)
+		case TieRandom:
+			top = []string{top[ga.rng.Intn(len(top))]}
+		default:
+			points /= len(top)
+		}
+	}
+
+	ga.winners = []string{}
+	for _, id := range top {
+		if player, _ := ga.candidate(id); player != nil {
+			ga.award(player, points)
+			if !containsString(ga.winners, player.Name) {
+				ga.winners = append(ga.winners, player.Name)
 			}
-		} else {
-			gameInstructions = "Click Next for another game"
-			roundInstructions = ""
 		}
 	}
+	ga.runoff = nil
+	ga.finishGame()
+}
 
-	stateData := map[string]interface{}{
-		"game_title":         gameTitle,
-		"game_instructions":  gameInstructions,
-		"round_instructions": roundInstructions,
-		"players":            playersList,
-		"game_state":         ga.state,
-		"game_type":          ga.currentGame,
-		"needs_input":        ga.game != nil && ga.game.NeedsInput(),
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
 	}
+	return false
+}
 
-	// Add timer data if game has a timer
-	if ga.state == "playing" && ga.game != nil && ga.game.HasTimer() {
-		stateData["has_timer"] = true
-		stateData["time_remaining"] = ga.game.GetTimeRemaining()
+// ownTeamVote reports whether a vote in team play goes to the voter's own
+// team while another team has someone to vote for
+func (ga *GameActor) ownTeamVote(voterID, candidateID string) bool {
+	if ga.teams == nil {
+		return false
+	}
+	team := ga.teams.TeamOf(voterID)
+	if team == 0 || ga.teams.TeamOf(candidateID) != team {
+		return false
+	}
+	for id := range ga.players {
+		if other := ga.teams.TeamOf(id); other != 0 && other != team {
+			return true
+		}
+	}
+	return false
+}
+
+func (ga *GameActor) handlePing(msg PingMsg) {
+	ga.mu.RLock()
+	defer ga.mu.RUnlock()
+
+	if player, exists := ga.member(msg.PlayerID); exists {
+		ga.sendToPlayer(player, PongEvent{Action: "pong"})
+	}
+}
+
+func (ga *GameActor) handleNotifyPlayer(msg NotifyPlayerMsg) {
+	ga.mu.RLock()
+	defer ga.mu.RUnlock()
+
+	if player, exists := ga.member(msg.PlayerID); exists {
+		ga.sendToPlayer(player, msg.Event)
+	}
+}
+
+func (ga *GameActor) handleRoomReset(msg RoomResetMsg) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	for _, player := range ga.everyone() {
+		ga.sendToPlayer(player, RoomResetEvent{Action: "room-reset", Reason: msg.Reason})
+	}
+	ga.broadcastState()
+}
+
+// CloseConnections sends every player a final event and closes their
+// connections once it has been written. Used when the room is shut down.
+func (ga *GameActor) CloseConnections(event interface{}) {
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+
+	for _, player := range ga.everyone() {
+		ga.sendToPlayer(player, event)
+		player.mu.Lock()
+		if player.out != nil {
+			player.out.CloseAfterFlush()
+		}
+		player.mu.Unlock()
+	}
+}
+
+func (ga *GameActor) handleResync(msg ResyncMsg) {
+	ga.mu.RLock()
+	defer ga.mu.RUnlock()
+
+	if player, exists := ga.member(msg.PlayerID); exists {
+		player.mu.Lock()
+		player.lastState = nil
+		player.mu.Unlock()
+		ga.sendState(player, ga.buildRoomState())
+	}
+}
+
+// sendSession tells a player the ID and token to resume with after a drop
+func (ga *GameActor) sendSession(player *Player) {
+	version := player.Protocol
+	if version == 0 {
+		version = ProtocolVersion
+	}
+	ga.sendToPlayer(player, SessionEvent{
+		Action:          "session",
+		PlayerID:        player.ID,
+		Token:           player.Token,
+		ProtocolVersion: version,
+	})
+}
+
+// sendToPlayer queues a JSON message for a single player
+func (ga *GameActor) sendToPlayer(player *Player, msg interface{}) {
+	player.mu.Lock()
+	defer player.mu.Unlock()
+
+	if player.out == nil {
+		return
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error marshaling message for player %s: %v", player.ID, err)
+		return
+	}
+
+	if !player.out.Push(data, false) {
+		ga.handleOverflow(player)
+	}
+}
+
+// handleOverflow applies the outbox policy to a player who has fallen
+// behind. Coalescing is handled by sendState, so here it just drops. Must be
+// called with player.mu held.
+func (ga *GameActor) handleOverflow(player *Player) {
+	switch ga.outbox.Policy {
+	case OverflowDisconnect:
+		log.Printf("Player %s fell behind in game %s, disconnecting", player.ID, ga.id)
+		player.out.Close()
+	default:
+		log.Printf("Player %s fell behind in game %s, dropping message", player.ID, ga.id)
+	}
+}
+
+func (ga *GameActor) handleGetGameState(msg GetGameStateMsg) *GameState {
+	ga.mu.RLock()
+	defer ga.mu.RUnlock()
+
+	state := &GameState{
+		ID:          ga.id,
+		State:       ga.state,
+		CurrentGame: ga.currentGame,
+		Players:     make(map[string]*PlayerInfo),
+		HostID:      ga.hostID,
+		Locked:      ga.locked,
+		Seed:        ga.seed,
+		RoundSeed:   ga.roundSeed,
+		Spectators:  len(ga.spectators),
+	}
+	if ga.teams != nil {
+		state.Teams = ga.teams.Data()
+	}
+
+	for id, p := range ga.players {
+		state.Players[id] = &PlayerInfo{
+			ID:           p.ID,
+			Name:         p.Name,
+			Score:        p.Score,
+			Ready:        p.Ready,
+			Disconnected: p.Disconnected,
+		}
+		if ga.teams != nil {
+			state.Players[id].Team = ga.teams.TeamOf(id)
+		}
+	}
+
+	return state
+}
+
+// roomState is the part of a state update shared by every player
+type roomState struct {
+	data     StateData
+	base     PlayerView
+	viewGame GameType
+}
+
+// broadcastState sends every player the changes since their last update
+func (ga *GameActor) broadcastState() {
+	ga.seq++
+	room := ga.buildRoomState()
+	for _, player := range ga.everyone() {
+		ga.sendState(player, room)
 	}
+}
 
-	// Add YouTube video ID for You Laugh You Lose
-	if ga.currentGame == "youlaughyoulose" && ga.game != nil {
-		if ylyl, ok := ga.game.(*YouLaughYouLose); ok {
-			stateData["youtube_video_id"] = ylyl.videoID
+func (ga *GameActor) buildRoomState() roomState {
+	// Players are sorted so deltas don't churn on map order
+	playersList := make([]PlayerData, 0, len(ga.players))
+	for _, p := range ga.players {
+		data := PlayerData{
+			ID:        p.ID,
+			Name:      p.Name,
+			Score:     p.Score,
+			Ready:     p.Ready,
+			Connected: !p.Disconnected,
+		}
+		if ga.teams != nil {
+			data.Team = ga.teams.TeamOf(p.ID)
+		}
+		playersList = append(playersList, data)
+	}
+	sort.Slice(playersList, func(i, j int) bool { return playersList[i].ID < playersList[j].ID })
+
+	base, viewGame := ga.baseView()
+
+	stateData := StateData{
+		Players:    playersList,
+		GameState:  ga.state,
+		GameType:   ga.currentGame,
+		HostID:     ga.hostID,
+		Locked:     ga.locked,
+		Settings:   ga.settings,
+		Spectators: len(ga.spectators),
+	}
+	if ga.currentGame != "" {
+		stateData.Round = ga.round
+	}
+	// The round seed recreates the game, secrets and all, so it's only
+	// shown once the round is over. The room seed would predict every
+	// round, so it stays in the event log.
+	if ga.state == "finished" || ga.state == "podium" {
+		stateData.RoundSeed = ga.roundSeed
+	}
+	if ga.teams != nil {
+		stateData.Teams = ga.teams.Data()
+		if ga.state == "playing" {
+			stateData.ActingTeam = ga.actingTeam
 		}
 	}
 
+	// The lobby UI lets the host change selection settings between games
+	if ga.isBetweenGames() {
+		stateData.Selection = ga.selector.Data()
+		stateData.AvailablePacks = contentPacks.Packs()
+	}
+	stateData.Packs = ga.packs
+	stateData.RoomTemplates = ga.templates
+
+	stateData.Match = ga.match
+	stateData.GamesPlayed = ga.gamesPlayed
+	if ga.state == "podium" {
+		stateData.Podium = ga.standings()
+	}
+
+	// Add timer data if game has a timer
+	if ga.state == "playing" && ga.game != nil && ga.game.HasTimer() {
+		timeRemaining := ga.game.GetTimeRemaining()
+		stateData.HasTimer = true
+		stateData.TimeRemaining = &timeRemaining
+	}
+
 	// Add voting data
 	if ga.state == "voting" {
 		votedPlayers := []string{}
 		for playerID := range ga.votes {
 			votedPlayers = append(votedPlayers, playerID)
 		}
-		stateData["voted_players"] = votedPlayers
-		stateData["total_votes"] = len(ga.votes)
-		stateData["expected_votes"] = len(ga.players)
+		sort.Strings(votedPlayers)
+		totalVotes, expectedVotes := len(ga.votes), ga.connectedCount()
+		stateData.VotedPlayers = votedPlayers
+		stateData.TotalVotes = &totalVotes
+		stateData.ExpectedVotes = &expectedVotes
+		stateData.Runoff = ga.runoff
+		if ga.voteRemaining > 0 {
+			voteRemaining := ga.voteRemaining
+			stateData.HasTimer = true
+			stateData.TimeRemaining = &voteRemaining
+		}
 	}
 
-	// Add game-specific data for Mad Libs
-	if ga.state == "playing" && ga.currentGame == "madlibs" && ga.game != nil {
-		if madlib, ok := ga.game.(*MadLib); ok {
-			stateData["current_prompt"] = madlib.CurrentPrompt()
-			stateData["words_collected"] = len(madlib.Words) - countEmpty(madlib.Words)
-			stateData["total_words"] = len(madlib.Words)
-		}
+	// Who voted for whom is only revealed once the vote is over
+	if ga.state == "finished" {
+		stateData.VoteResults = ga.voteResults
 	}
 
 	// Add completed result if finished
 	if ga.state == "finished" && ga.game != nil {
-		stateData["story"] = ga.game.GetResult()
+		stateData.Story = ga.game.GetResult()
 	}
 
-	stateMsg := map[string]interface{}{
-		"state": stateData,
+	return roomState{data: stateData, base: base, viewGame: viewGame}
+}
+
+// sendState sends one player their personalized state, as a patch against
+// what they last received or as a full snapshot if they have nothing yet
+func (ga *GameActor) sendState(player *Player, room roomState) {
+	player.mu.Lock()
+	defer player.mu.Unlock()
+
+	if player.out == nil {
+		return
 	}
 
-	// Send to all players (personalized for Imitations)
-	for _, player := range ga.players {
-		player.mu.Lock()
-		if player.Conn != nil {
-			// Personalize message for Imitations and Charades games
-			playerStateMsg := stateMsg
-			if ga.state == "playing" && ga.currentGame == "imitations" {
-				if im, ok := ga.game.(*Imitations); ok {
-					playerStateData := make(map[string]interface{})
-					for k, v := range stateData {
-						playerStateData[k] = v
-					}
-
-					// Actor gets told who to imitate
-					if player.ID == im.GetActor() {
-						playerStateData["game_title"] = "Imitate " + im.GetPerson() + "!"
-						playerStateData["game_instructions"] = ""
-						playerStateData["round_instructions"] = ""
-						playerStateData["needs_input"] = false // Actor doesn't submit anything
-					} else {
-						// Guessers get the normal prompt
-						playerStateData["game_title"] = "Guess who's being imitated!"
-						playerStateData["game_instructions"] = "Enter your answer:"
-						playerStateData["round_instructions"] = ""
-						playerStateData["needs_input"] = true // Guesser needs to submit
-					}
-
-					playerStateMsg = map[string]interface{}{
-						"state": playerStateData,
-					}
-				}
-			} else if ga.state == "playing" && ga.currentGame == "charades" {
-				if ch, ok := ga.game.(*Charades); ok {
-					playerStateData := make(map[string]interface{})
-					for k, v := range stateData {
-						playerStateData[k] = v
-					}
-
-					// Actor gets told what to act out
-					if player.ID == ch.GetActor() {
-						playerStateData["game_title"] = "Act out: " + ch.GetTopic() + "!"
-						playerStateData["game_instructions"] = ""
-						playerStateData["round_instructions"] = ""
-						playerStateData["needs_input"] = false // Actor doesn't submit anything
-					} else {
-						// Guessers get the normal prompt
-						playerStateData["game_title"] = "Guess what's being acted out!"
-						playerStateData["game_instructions"] = "Enter your answer:"
-						playerStateData["round_instructions"] = ""
-						playerStateData["needs_input"] = true // Guesser needs to submit
-					}
-
-					playerStateMsg = map[string]interface{}{
-						"state": playerStateData,
-					}
-				}
-			} else if ga.state == "playing" && ga.currentGame == "madlibs" {
-				if madlib, ok := ga.game.(*MadLib); ok {
-					playerStateData := make(map[string]interface{})
-					for k, v := range stateData {
-						playerStateData[k] = v
-					}
-
-					// Each player gets their own personalized prompt
-					playerPrompt := madlib.GetPromptForPlayer(player.ID)
-					if playerPrompt != "" {
-						playerStateData["game_title"] = playerPrompt
-						playerStateData["current_prompt"] = playerPrompt
-						playerStateData["needs_input"] = true
-					} else {
-						// No slots available - all slots are either claimed by others or filled
-						playerStateData["game_title"] = "Please wait..."
-						playerStateData["game_instructions"] = "All words are being filled by other players"
-						playerStateData["round_instructions"] = ""
-						playerStateData["needs_input"] = false
-					}
-
-					playerStateMsg = map[string]interface{}{
-						"state": playerStateData,
-					}
-				}
+	view := room.base
+	view.Extra = make(map[string]interface{})
+	if room.viewGame != nil {
+		view = room.viewGame.PlayerView(view, player.ID, player.Role)
+	}
+
+	stateData := room.data
+	if player.Role == RoleSpectator {
+		view.NeedsInput = false
+		stateData.Role = string(RoleSpectator)
+	}
+	if acting := stateData.ActingTeam; acting != 0 && ga.teams.TeamOf(player.ID) != acting {
+		view.NeedsInput = false
+		view.RoundInstructions = teamNames[acting-1] + " team is guessing. Watch them go!"
+	}
+	stateData.GameTitle = view.Title
+	stateData.GameInstructions = view.Instructions
+	stateData.RoundInstructions = view.RoundInstructions
+	stateData.NeedsInput = view.NeedsInput
+	stateData.Extra = view.Extra
+
+	current, err := toJSONValue(stateData)
+	if err != nil {
+		log.Printf("Error marshaling player state: %v", err)
+		return
+	}
+
+	var event interface{}
+	if player.lastState == nil {
+		event = StateEvent{Action: "state", Seq: ga.seq, State: stateData}
+	} else {
+		ops := DiffJSON(player.lastState, current)
+		if len(ops) == 0 {
+			return
+		}
+		event = PatchEvent{Action: "patch", Seq: ga.seq, BaseSeq: player.lastSeq, Ops: ops}
+	}
+
+	playerJsonData, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshaling player state: %v", err)
+		return
+	}
+
+	if !player.out.Push(playerJsonData, true) {
+		player.lastState = nil // we don't know what they'll get
+
+		if ga.outbox.Policy != OverflowCoalesce {
+			ga.handleOverflow(player)
+			return
+		}
+
+		// Replace everything they haven't read yet with one snapshot
+		log.Printf("Player %s fell behind in game %s, coalescing to a snapshot", player.ID, ga.id)
+		player.out.DropState()
+		playerJsonData, err = json.Marshal(StateEvent{Action: "state", Seq: ga.seq, State: stateData})
+		if err != nil || !player.out.Push(playerJsonData, true) {
+			return
+		}
+	}
+	player.lastState = current
+	player.lastSeq = ga.seq
+}
+
+// baseView builds the view shared by every player for the current state,
+// along with the game instance the per-player view builder should use
+func (ga *GameActor) baseView() (PlayerView, GameType) {
+	view := PlayerView{
+		State:      ga.state,
+		NeedsInput: ga.game != nil && ga.game.NeedsInput(),
+	}
+	viewGame := ga.game
+	numPlayers := len(ga.players)
+
+	switch ga.state {
+	case "lobby", "":
+		if numPlayers == 0 {
+			view.Title = "Waiting for players to join"
+			view.Instructions = "Wait for players to join"
+			view.RoundInstructions = "Share this URL with friends to play together!"
+		} else if numPlayers == 1 {
+			view.Title = "Ready to play!"
+			view.Instructions = "Click 'Next' to start a game"
+			view.RoundInstructions = "Most games work solo! Or share the URL to play with friends."
+		} else {
+			view.Title = "Waiting for more players"
+			view.Instructions = "Wait for more players or click 'Next' to start"
+			view.RoundInstructions = "Ready to play?"
+		}
+
+	case "instructions":
+		if numPlayers == 1 {
+			view.RoundInstructions = "Click 'Next' when ready to play"
+		} else {
+			view.RoundInstructions = "Everyone click 'Next' when ready"
+		}
+		if ga.pending != nil {
+			viewGame = ga.pending
+			view.Title = viewGame.GetName()
+			view.Instructions = viewGame.GetInstructions()
+		} else {
+			view.Title = "Get Ready!"
+			view.Instructions = "Prepare for the next game"
+		}
+
+	case "playing":
+		if ga.game != nil {
+			// Use the prompt as the big title text
+			view.Title = ga.game.GetPrompt()
+			if ga.game.NeedsInput() {
+				view.Instructions = "Enter your answer:"
 			}
+		} else {
+			view.Title = "Playing..."
+			view.Instructions = "Loading..."
+		}
 
-			playerJsonData, err := json.Marshal(playerStateMsg)
-			if err != nil {
-				log.Printf("Error marshaling player state: %v", err)
-				player.mu.Unlock()
-				continue
+	case "voting":
+		view.Title = "Time to Vote!"
+		if ga.game != nil {
+			view.Instructions = ga.game.GetResult() // already contains voting instructions
+		} else {
+			view.Instructions = "Who kept the straightest face?"
+		}
+
+	case "finished":
+		view.Title = "Game Complete!"
+		if ga.settings.Rounds > 1 {
+			view.Title = fmt.Sprintf("Round %d of %d Complete!", ga.round, ga.settings.Rounds)
+		}
+		if ga.game != nil {
+			view.Instructions = ga.game.GetName() + " finished!"
+			winnersLine := ""
+			if len(ga.winners) == 1 {
+				winnersLine = ga.winners[0] + " wins!"
+			} else if len(ga.winners) > 1 {
+				winnersLine = "Tie! " + strings.Join(ga.winners, ", ") + " win!"
+			}
+			result := ga.game.GetResult()
+			if own, ok := ga.game.(OwnResultGame); ok && own.ShowsOwnResult() {
+				result = ""
 			}
+			view.RoundInstructions = strings.TrimSpace(winnersLine + " " + result)
+		} else {
+			view.Instructions = "Click Next for another game"
+		}
 
-			err = player.Conn.WriteMessage(websocket.TextMessage, playerJsonData)
-			if err != nil {
-				log.Printf("Error sending to player %s: %v", player.ID, err)
+	case "podium":
+		view.Title = "Match Over!"
+		var champions []string
+		for _, standing := range ga.standings() {
+			if standing.Place == 1 {
+				champions = append(champions, standing.Name)
 			}
 		}
-		player.mu.Unlock()
+		if len(champions) == 1 {
+			view.Instructions = champions[0] + " wins the match!"
+		} else if len(champions) > 1 {
+			view.Instructions = "Tie! " + strings.Join(champions, ", ") + " share the match!"
+		}
+		view.RoundInstructions = fmt.Sprintf("%d games played. The host can start a rematch.", ga.gamesPlayed)
 	}
+
+	return view, viewGame
 }
 
 func countEmpty(words []string) int {
@@ -648,46 +2095,92 @@ func countEmpty(words []string) int {
 	return count
 }
 
-// assignRandomActor picks a random player as the actor for games that need one (Imitations, Charades)
+// assignRandomActor picks a random player as the actor for games that need
+// one, or the next in the team rotation in team play
 func (ga *GameActor) assignRandomActor() {
-	if len(ga.players) == 0 {
+	desc, _ := LookupGame(ga.currentGame)
+	actorGame, ok := ga.game.(ActorGame)
+	if !desc.NeedsActor || !ok || len(ga.players) == 0 {
 		return
 	}
 
-	// Build list of player IDs
+	// Build list of connected player IDs
 	playerIDs := make([]string, 0, len(ga.players))
-	for id := range ga.players {
-		playerIDs = append(playerIDs, id)
-	}
-	actorID := playerIDs[rand.Intn(len(playerIDs))]
-
-	// Assign actor based on game type
-	switch ga.currentGame {
-	case "imitations":
-		if im, ok := ga.game.(*Imitations); ok {
-			im.SetActor(actorID)
-			log.Printf("Set %s as actor for Imitations in game %s", actorID, ga.id)
+	for id, p := range ga.players {
+		if !p.Disconnected {
+			playerIDs = append(playerIDs, id)
 		}
-	case "charades":
-		if ch, ok := ga.game.(*Charades); ok {
-			ch.SetActor(actorID)
-			log.Printf("Set %s as actor for Charades in game %s", actorID, ga.id)
+	}
+	if len(playerIDs) == 0 {
+		return
+	}
+	sort.Strings(playerIDs) // map order isn't reproducible, the rng is
+
+	// In team play the teams take turns, each rotating through its members
+	if ga.teams != nil {
+		if team, actorID := ga.teams.NextActor(playerIDs); team != 0 {
+			ga.actingTeam = team
+			actorGame.SetActor(actorID)
+			log.Printf("Set %s of team %d as actor for %s in game %s", actorID, team, desc.Name, ga.id)
+			return
 		}
 	}
+
+	actorID := playerIDs[ga.rng.Intn(len(playerIDs))]
+	actorGame.SetActor(actorID)
+	log.Printf("Set %s as actor for %s in game %s", actorID, desc.Name, ga.id)
 }
 
-// activateTimerIfNeeded starts the timer for timed games (FirstToFind, BlankestBlank)
+// activateTimerIfNeeded starts the round timer for timed games, and the
+// ticker for slot games' claim timeouts
 func (ga *GameActor) activateTimerIfNeeded() {
-	switch ga.currentGame {
-	case "firsttofind":
-		if ftf, ok := ga.game.(*FirstToFind); ok {
-			ftf.timerActive = true
-			log.Printf("Started timer for First to Find in game %s", ga.id)
-		}
-	case "blankestblank":
-		if bb, ok := ga.game.(*BlankestBlank); ok {
-			bb.timerActive = true
-			log.Printf("Started timer for Blankest Blank in game %s", ga.id)
+	if _, ok := ga.game.(SlotGame); ok && ga.settings.ClaimSeconds > 0 {
+		ga.startTimer()
+		return
+	}
+
+	desc, _ := LookupGame(ga.currentGame)
+	seconds := ga.settings.GameConfig(desc).TimerSeconds
+	timed, ok := ga.game.(TimedGame)
+	if seconds <= 0 || !ok {
+		return
+	}
+
+	timed.StartTimer(seconds)
+	ga.startTimer()
+	log.Printf("Started %ds timer for %s in game %s", seconds, desc.Name, ga.id)
+}
+
+// startTimer starts a ticker that sends TimerTickMsg to this actor until
+// stopTimer is called. Must be called with ga.mu held.
+func (ga *GameActor) startTimer() {
+	if ga.timerStop != nil || ga.replaying {
+		return
+	}
+
+	stop := make(chan struct{})
+	ga.timerStop = stop
+	interval := ga.tickInterval
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ga.Send(TimerTickMsg{})
+			case <-stop:
+				return
+			}
 		}
+	}()
+}
+
+// stopTimer stops the round ticker if one is running. Must be called with
+// ga.mu held.
+func (ga *GameActor) stopTimer() {
+	if ga.timerStop != nil {
+		close(ga.timerStop)
+		ga.timerStop = nil
 	}
 }
@@ -1,20 +1,48 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"reflect"
 	"testing"
 	"time"
 )
 
+// askState asks a game actor for its state, failing the test if it doesn't
+// answer
+func askState(t *testing.T, ga *GameActor) *GameState {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	state, err := ga.GetState(ctx)
+	if err != nil {
+		t.Fatalf("GetState failed: %v", err)
+	}
+	return state
+}
+
+// askResume resumes the session holding token and returns the player ID
+func askResume(t *testing.T, ga *GameActor, token string) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	reply, err := ga.Ask(ctx, PlayerResumeMsg{Token: token})
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	return reply.(string)
+}
+
 func TestGameActorCreation(t *testing.T) {
 	ga := NewGameActor("test-game")
 	ga.Start()
 	defer ga.Stop()
 
 	// Query game state
-	responseChan := make(chan *GameState, 1)
-	ga.Send(GetGameStateMsg{ResponseChan: responseChan})
-
-	state := <-responseChan
+	state := askState(t, ga)
 
 	if state.ID != "test-game" {
 		t.Errorf("Expected game ID 'test-game', got '%s'", state.ID)
@@ -45,9 +73,7 @@ func TestGameActorPlayerJoin(t *testing.T) {
 	time.Sleep(50 * time.Millisecond)
 
 	// Query state
-	responseChan := make(chan *GameState, 1)
-	ga.Send(GetGameStateMsg{ResponseChan: responseChan})
-	state := <-responseChan
+	state := askState(t, ga)
 
 	if len(state.Players) != 1 {
 		t.Fatalf("Expected 1 player, got %d", len(state.Players))
@@ -91,9 +117,7 @@ func TestGameActorPlayerLeave(t *testing.T) {
 	time.Sleep(50 * time.Millisecond)
 
 	// Query state
-	responseChan := make(chan *GameState, 1)
-	ga.Send(GetGameStateMsg{ResponseChan: responseChan})
-	state := <-responseChan
+	state := askState(t, ga)
 
 	if len(state.Players) != 0 {
 		t.Errorf("Expected no players after leave, got %d", len(state.Players))
@@ -116,9 +140,7 @@ func TestGameActorStateTransitions(t *testing.T) {
 	time.Sleep(50 * time.Millisecond)
 
 	// Check initial state
-	responseChan := make(chan *GameState, 1)
-	ga.Send(GetGameStateMsg{ResponseChan: responseChan})
-	state := <-responseChan
+	state := askState(t, ga)
 
 	if state.State != "lobby" {
 		t.Errorf("Expected state 'lobby', got '%s'", state.State)
@@ -128,9 +150,7 @@ func TestGameActorStateTransitions(t *testing.T) {
 	ga.Send(NextGameMsg{PlayerID: "player1"})
 	time.Sleep(50 * time.Millisecond)
 
-	responseChan = make(chan *GameState, 1)
-	ga.Send(GetGameStateMsg{ResponseChan: responseChan})
-	state = <-responseChan
+	state = askState(t, ga)
 
 	if state.State != "instructions" {
 		t.Errorf("Expected state 'instructions', got '%s'", state.State)
@@ -144,9 +164,7 @@ func TestGameActorStateTransitions(t *testing.T) {
 	ga.Send(NextGameMsg{PlayerID: "player1"})
 	time.Sleep(50 * time.Millisecond)
 
-	responseChan = make(chan *GameState, 1)
-	ga.Send(GetGameStateMsg{ResponseChan: responseChan})
-	state = <-responseChan
+	state = askState(t, ga)
 
 	// Single player should be ready and game should start
 	if state.State != "playing" {
@@ -184,9 +202,7 @@ func TestGameActorMultiplePlayersReady(t *testing.T) {
 	ga.Send(NextGameMsg{PlayerID: "player1"})
 	time.Sleep(50 * time.Millisecond)
 
-	responseChan := make(chan *GameState, 1)
-	ga.Send(GetGameStateMsg{ResponseChan: responseChan})
-	state := <-responseChan
+	state := askState(t, ga)
 
 	// Should still be in instructions (not all ready)
 	if state.State != "instructions" {
@@ -197,9 +213,7 @@ func TestGameActorMultiplePlayersReady(t *testing.T) {
 	ga.Send(NextGameMsg{PlayerID: "player2"})
 	time.Sleep(50 * time.Millisecond)
 
-	responseChan = make(chan *GameState, 1)
-	ga.Send(GetGameStateMsg{ResponseChan: responseChan})
-	state = <-responseChan
+	state = askState(t, ga)
 
 	// Now should be playing
 	if state.State != "playing" {
@@ -227,11 +241,919 @@ func TestGameActorPing(t *testing.T) {
 	time.Sleep(50 * time.Millisecond)
 
 	// Verify player still exists
-	responseChan := make(chan *GameState, 1)
-	ga.Send(GetGameStateMsg{ResponseChan: responseChan})
-	state := <-responseChan
+	state := askState(t, ga)
 
 	if len(state.Players) != 1 {
 		t.Errorf("Expected 1 player after ping, got %d", len(state.Players))
 	}
 }
+
+func TestGameActorTimerEndsRound(t *testing.T) {
+	ga := NewGameActor("timer-test")
+	ga.tickInterval = 10 * time.Millisecond
+	ga.Start()
+	defer ga.Stop()
+
+	ga.Send(PlayerJoinMsg{
+		GameID:     "timer-test",
+		PlayerID:   "player1",
+		PlayerName: "Alice",
+		Conn:       nil,
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	// Force First to Find with a short timer
+	ga.mu.Lock()
+	ga.state = "instructions"
+	ga.currentGame = "firsttofind"
+	ga.mu.Unlock()
+
+	ga.Send(NextGameMsg{PlayerID: "player1"})
+	time.Sleep(50 * time.Millisecond)
+
+	ga.mu.Lock()
+	if ga.state != "playing" {
+		ga.mu.Unlock()
+		t.Fatalf("Expected state 'playing', got '%s'", ga.state)
+	}
+	if ga.game.GetTimeRemaining() >= 30 {
+		t.Errorf("Expected timer to be counting down, got %d", ga.game.GetTimeRemaining())
+	}
+	ga.mu.Unlock()
+
+	// 30 ticks at 10ms should finish well within a second
+	time.Sleep(500 * time.Millisecond)
+
+	state := askState(t, ga)
+
+	if state.State != "voting" {
+		t.Errorf("Expected state 'voting' after timer expired, got '%s'", state.State)
+	}
+}
+
+func TestGameActorClientCannotEndTimedRound(t *testing.T) {
+	ga := NewGameActor("timer-cheat")
+	ga.Start()
+	defer ga.Stop()
+
+	ga.Send(PlayerJoinMsg{
+		GameID:     "timer-cheat",
+		PlayerID:   "player1",
+		PlayerName: "Alice",
+		Conn:       nil,
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	ga.mu.Lock()
+	ga.state = "instructions"
+	ga.currentGame = "blankestblank"
+	ga.mu.Unlock()
+
+	ga.Send(NextGameMsg{PlayerID: "player1"})
+	time.Sleep(50 * time.Millisecond)
+
+	// The old client-side magic word must not end the round
+	ga.Send(SubmitWordMsg{PlayerID: "player1", Word: "timer_complete"})
+	time.Sleep(50 * time.Millisecond)
+
+	state := askState(t, ga)
+
+	if state.State != "playing" {
+		t.Errorf("Expected state 'playing', got '%s'", state.State)
+	}
+	if state.Players["player1"].Score != 0 {
+		t.Errorf("Expected no points for timer_complete, got %d", state.Players["player1"].Score)
+	}
+}
+
+func TestGameActorDisconnectKeepsPlayer(t *testing.T) {
+	ga := NewGameActor("resume-test")
+	ga.disconnectGrace = time.Hour
+	ga.Start()
+	defer ga.Stop()
+
+	ga.Send(PlayerJoinMsg{
+		GameID:     "resume-test",
+		PlayerID:   "player1",
+		PlayerName: "Alice",
+		Token:      "token1",
+		Conn:       nil,
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	ga.mu.Lock()
+	ga.players["player1"].Score = 5
+	ga.mu.Unlock()
+
+	ga.Send(PlayerDisconnectMsg{PlayerID: "player1", Conn: nil})
+	time.Sleep(50 * time.Millisecond)
+
+	state := askState(t, ga)
+
+	player, exists := state.Players["player1"]
+	if !exists {
+		t.Fatal("Expected disconnected player to be kept during grace period")
+	}
+	if !player.Disconnected {
+		t.Error("Expected player to be marked disconnected")
+	}
+
+	// Resume with the session token
+	if id := askResume(t, ga, "token1"); id != "player1" {
+		t.Fatalf("Expected resume to return 'player1', got '%s'", id)
+	}
+
+	state = askState(t, ga)
+
+	player = state.Players["player1"]
+	if player.Disconnected {
+		t.Error("Expected player to be connected after resume")
+	}
+	if player.Score != 5 {
+		t.Errorf("Expected score to survive resume, got %d", player.Score)
+	}
+}
+
+func TestGameActorResumeUnknownToken(t *testing.T) {
+	ga := NewGameActor("resume-unknown")
+	ga.Start()
+	defer ga.Stop()
+
+	if id := askResume(t, ga, "nope"); id != "" {
+		t.Errorf("Expected unknown token to fail, got '%s'", id)
+	}
+}
+
+func TestGameActorDisconnectGraceExpires(t *testing.T) {
+	ga := NewGameActor("grace-test")
+	ga.disconnectGrace = 20 * time.Millisecond
+	ga.Start()
+	defer ga.Stop()
+
+	ga.Send(PlayerJoinMsg{
+		GameID:     "grace-test",
+		PlayerID:   "player1",
+		PlayerName: "Alice",
+		Token:      "token1",
+		Conn:       nil,
+	})
+	ga.Send(PlayerDisconnectMsg{PlayerID: "player1", Conn: nil})
+	time.Sleep(100 * time.Millisecond)
+
+	state := askState(t, ga)
+
+	if len(state.Players) != 0 {
+		t.Errorf("Expected player removed after grace period, got %d players", len(state.Players))
+	}
+}
+
+// askJoin joins a player with Ask and returns the room's answer
+func askJoin(t *testing.T, ga *GameActor, msg PlayerJoinMsg) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := ga.Ask(ctx, msg)
+	return err
+}
+
+func TestGameActorHostControlsNextGame(t *testing.T) {
+	ga := NewGameActor("test-game")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "player1", PlayerName: "Alice"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "player2", PlayerName: "Bob"})
+
+	state := askState(t, ga)
+	if state.HostID != "player1" {
+		t.Fatalf("Expected first joiner to be host, got '%s'", state.HostID)
+	}
+
+	// Only the host can start a game
+	ga.Send(NextGameMsg{PlayerID: "player2"})
+	if state := askState(t, ga); state.State != "lobby" {
+		t.Errorf("Expected non-host next-game to be ignored, got '%s'", state.State)
+	}
+
+	ga.Send(NextGameMsg{PlayerID: "player1"})
+	if state := askState(t, ga); state.State != "instructions" {
+		t.Errorf("Expected host to start a game, got '%s'", state.State)
+	}
+
+	// Host passes on when the host leaves
+	ga.Send(PlayerLeaveMsg{PlayerID: "player1"})
+	if state := askState(t, ga); state.HostID != "player2" {
+		t.Errorf("Expected host to pass to player2, got '%s'", state.HostID)
+	}
+}
+
+func TestGameActorHostDropsAndResumes(t *testing.T) {
+	ga := NewGameActor("test-game")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "player1", PlayerName: "Alice", Token: "alice-token"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "player2", PlayerName: "Bob"})
+
+	// The room doesn't wait out the grace period for its host
+	ga.Send(PlayerDisconnectMsg{PlayerID: "player1"})
+	if state := askState(t, ga); state.HostID != "player2" {
+		t.Fatalf("Expected host to pass to the connected player, got '%s'", state.HostID)
+	}
+	ga.Send(NextGameMsg{PlayerID: "player2"})
+	if state := askState(t, ga); state.State != "instructions" {
+		t.Errorf("Expected the new host to start a game, got '%s'", state.State)
+	}
+
+	if id := askResume(t, ga, "alice-token"); id != "player1" {
+		t.Fatalf("Expected Alice to resume, got '%s'", id)
+	}
+	if state := askState(t, ga); state.HostID != "player1" {
+		t.Errorf("Expected the host to get the room back on resume, got '%s'", state.HostID)
+	}
+}
+
+func TestGameActorAuthenticatedUserTakesHost(t *testing.T) {
+	ga := NewGameActor("test-game")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "guest", PlayerName: "Guest"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "owner", PlayerName: "Owner", RemoteUser: "owner"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "other", PlayerName: "Other", RemoteUser: "other"})
+
+	if state := askState(t, ga); state.HostID != "owner" {
+		t.Errorf("Expected the first authenticated user to take host, got '%s'", state.HostID)
+	}
+}
+
+func TestGameActorKickAndBan(t *testing.T) {
+	ga := NewGameActor("test-game")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "host", PlayerName: "Host"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "player2", PlayerName: "Bob", Token: "bob-token"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "player3", PlayerName: "Carol"})
+
+	// Non-hosts can't kick
+	ga.Send(KickPlayerMsg{PlayerID: "player2", TargetID: "player3"})
+	if state := askState(t, ga); len(state.Players) != 3 {
+		t.Fatalf("Expected non-host kick to be ignored, got %d players", len(state.Players))
+	}
+
+	ga.Send(KickPlayerMsg{PlayerID: "host", TargetID: "player3"})
+	ga.Send(KickPlayerMsg{PlayerID: "host", TargetID: "player2", Ban: true})
+	if state := askState(t, ga); len(state.Players) != 1 {
+		t.Fatalf("Expected kicked players to be removed, got %d players", len(state.Players))
+	}
+
+	// A kicked player may come back, a banned one may not
+	if err := askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "player4", PlayerName: "Carol"}); err != nil {
+		t.Errorf("Expected kicked player to rejoin, got %v", err)
+	}
+	err := askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "player5", PlayerName: "bob"})
+	if perr, ok := err.(*ProtocolError); !ok || perr.Code != ErrCodeBanned {
+		t.Errorf("Expected banned player to be turned away, got %v", err)
+	}
+	if id := askResume(t, ga, "bob-token"); id != "" {
+		t.Errorf("Expected banned session not to resume, got '%s'", id)
+	}
+}
+
+func TestGameActorLockAndSkip(t *testing.T) {
+	ga := NewGameActor("test-game")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "host", PlayerName: "Host"})
+
+	ga.Send(LockRoomMsg{PlayerID: "host", Locked: true})
+	err := askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "late", PlayerName: "Late"})
+	if perr, ok := err.(*ProtocolError); !ok || perr.Code != ErrCodeRoomLocked {
+		t.Errorf("Expected locked room to turn players away, got %v", err)
+	}
+
+	ga.Send(NextGameMsg{PlayerID: "host"})
+	ga.Send(NextGameMsg{PlayerID: "host"})
+	state := askState(t, ga)
+	if state.State != "playing" {
+		t.Fatalf("Expected 'playing', got '%s'", state.State)
+	}
+
+	ga.Send(SkipGameMsg{PlayerID: "host"})
+	if state := askState(t, ga); state.State != "instructions" || !state.Locked {
+		t.Errorf("Expected skip to go to the next game's instructions in a locked room, got '%s' (locked %v)", state.State, state.Locked)
+	}
+}
+
+func TestGameActorHostPicksNextGame(t *testing.T) {
+	ga := NewGameActor("test-game")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "host", PlayerName: "Host"})
+	ga.Send(SetSelectionMsg{PlayerID: "host", Mode: SelectHostPicks})
+
+	// Without a pick there's nothing to start
+	ga.Send(NextGameMsg{PlayerID: "host"})
+	if state := askState(t, ga); state.State != "lobby" {
+		t.Fatalf("Expected to stay in lobby without a pick, got '%s'", state.State)
+	}
+
+	// Disabled games can't be picked
+	ga.Send(EnableGameMsg{PlayerID: "host", Game: "claudesgame", Enabled: false})
+	ga.Send(NextGameMsg{PlayerID: "host", Game: "claudesgame"})
+	if state := askState(t, ga); state.State != "lobby" {
+		t.Fatalf("Expected disabled pick to be refused, got '%s'", state.State)
+	}
+
+	ga.Send(NextGameMsg{PlayerID: "host", Game: "madlibs"})
+	state := askState(t, ga)
+	if state.State != "instructions" || state.CurrentGame != "madlibs" {
+		t.Errorf("Expected the host's pick to be next, got '%s' in '%s'", state.CurrentGame, state.State)
+	}
+}
+
+func TestGameActorSettingsTimerAndRounds(t *testing.T) {
+	ga := NewGameActor("test-game")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "host", PlayerName: "Host"})
+
+	// Out of range settings are refused
+	ga.Send(UpdateSettingsMsg{PlayerID: "host", Changes: json.RawMessage(`{"timer_seconds": 5}`)})
+	ga.Send(UpdateSettingsMsg{PlayerID: "host", Changes: json.RawMessage(`{"rounds": "two"}`)})
+	askState(t, ga)
+	ga.mu.Lock()
+	if ga.settings != DefaultRoomSettings() {
+		t.Errorf("Expected bad settings to be refused, got %+v", ga.settings)
+	}
+	ga.mu.Unlock()
+
+	// Fields left out keep their values
+	ga.Send(UpdateSettingsMsg{PlayerID: "host", Changes: json.RawMessage(`{"timer_seconds": 15}`)})
+	ga.Send(UpdateSettingsMsg{PlayerID: "host", Changes: json.RawMessage(`{"rounds": 2}`)})
+	askState(t, ga)
+	ga.mu.Lock()
+	settings := DefaultRoomSettings()
+	settings.TimerSeconds = 15
+	settings.Rounds = 2
+	if ga.settings != settings {
+		t.Errorf("Expected only the timer and rounds to change, got %+v", ga.settings)
+	}
+	ga.mu.Unlock()
+	ga.Send(NextGameMsg{PlayerID: "host", Game: "firsttofind"})
+	ga.Send(NextGameMsg{PlayerID: "host"}) // ready
+	askState(t, ga)
+
+	ga.mu.Lock()
+	if ga.state != "playing" || ga.game.GetTimeRemaining() > 15 {
+		t.Errorf("Expected a 15s round, got %d left in '%s'", ga.game.GetTimeRemaining(), ga.state)
+	}
+	ga.state = "finished"
+	ga.mu.Unlock()
+
+	// Settings are locked during a game, and the second round is the same game
+	ga.Send(NextGameMsg{PlayerID: "host"})
+	askState(t, ga)
+	ga.mu.Lock()
+	if ga.state != "playing" || ga.currentGame != "firsttofind" || ga.round != 2 {
+		t.Errorf("Expected round 2 of firsttofind, got round %d of %s in '%s'", ga.round, ga.currentGame, ga.state)
+	}
+	ga.state = "finished"
+	ga.mu.Unlock()
+
+	ga.Send(NextGameMsg{PlayerID: "host"})
+	if state := askState(t, ga); state.State != "instructions" {
+		t.Errorf("Expected a new game after the last round, got '%s'", state.State)
+	}
+}
+
+func TestGameActorReportsFinishedGame(t *testing.T) {
+	ga := NewGameActor("test-game")
+	records := make(chan GameRecord, 1)
+	ga.OnGameOver(func(r GameRecord) { records <- r })
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "p1", PlayerName: "Alice", RemoteUser: "alice"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "p2", PlayerName: "Bob"})
+
+	ga.mu.Lock()
+	ga.players["p1"].Score = 10 // points from earlier games don't count
+	ga.mu.Unlock()
+
+	ga.Send(NextGameMsg{PlayerID: "p1", Game: "claudesgame"})
+	ga.Send(NextGameMsg{PlayerID: "p1"})
+	ga.Send(NextGameMsg{PlayerID: "p2"})
+	ga.Send(SubmitWordMsg{PlayerID: "p1", Word: "a"})
+	ga.Send(SubmitWordMsg{PlayerID: "p2", Word: "b"})
+	ga.Send(VoteMsg{PlayerID: "p1", VotedForID: "p2"})
+	ga.Send(VoteMsg{PlayerID: "p2", Abstain: true})
+
+	select {
+	case r := <-records:
+		if r.Game != "claudesgame" || len(r.Winners) != 1 || r.Winners[0] != "Bob" {
+			t.Errorf("Expected Bob to win claudesgame, got %+v", r)
+		}
+		alice, bob := r.Players[0], r.Players[1]
+		if alice.User != "alice" || alice.Points != 1 || alice.Won {
+			t.Errorf("Unexpected result for Alice: %+v", alice)
+		}
+		if bob.Points != 4 || !bob.Won {
+			t.Errorf("Unexpected result for Bob: %+v", bob)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the finished game to be reported")
+	}
+}
+
+func TestGameActorSeedReproducesChoices(t *testing.T) {
+	type choice struct {
+		topic, actor, instructions string
+		roundSeed                  int64
+	}
+	play := func(seed int64) choice {
+		ga := NewGameActorWithSeed("seed-test", seed)
+		ga.Start()
+		defer ga.Stop()
+
+		for _, id := range []string{"p1", "p2", "p3"} {
+			askJoin(t, ga, PlayerJoinMsg{GameID: "seed-test", PlayerID: id, PlayerName: id})
+		}
+		ga.Send(SetSelectionMsg{PlayerID: "p1", Mode: SelectPlaylist, Playlist: []string{"charades"}})
+		ga.Send(NextGameMsg{PlayerID: "p1"})
+		askState(t, ga)
+
+		ga.mu.Lock()
+		instructions := ga.pending.GetInstructions()
+		ga.mu.Unlock()
+
+		for _, id := range []string{"p1", "p2", "p3"} {
+			ga.Send(NextGameMsg{PlayerID: id})
+		}
+		state := askState(t, ga)
+
+		ga.mu.Lock()
+		defer ga.mu.Unlock()
+		// The round seed would give the topic away to every guesser
+		if ga.buildRoomState().data.RoundSeed != 0 {
+			t.Error("Expected the round seed to stay off state updates during play")
+		}
+		return choice{
+			topic:        ga.game.(*Charades).topic,
+			actor:        ga.game.(*Charades).GetActor(),
+			instructions: instructions,
+			roundSeed:    state.RoundSeed,
+		}
+	}
+
+	first, second := play(99), play(99)
+	if first != second {
+		t.Errorf("Expected the same seed to make the same choices, got %+v and %+v", first, second)
+	}
+	if first.roundSeed == 0 {
+		t.Error("Expected the round seed in the state")
+	}
+
+	// The round seed alone recreates the round's game
+	game := NewCharades(GameConfig{Rand: rand.New(rand.NewSource(first.roundSeed))})
+	if game.topic != first.topic {
+		t.Errorf("Expected round seed to give topic %q, got %q", first.topic, game.topic)
+	}
+}
+
+func TestGameActorSpectatorsDontPlay(t *testing.T) {
+	ga := NewGameActor("test-game")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "p1", PlayerName: "Alice"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "p2", PlayerName: "Bob"})
+	ga.Send(LockRoomMsg{PlayerID: "p1", Locked: true})
+	if err := askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "s1", PlayerName: "Stream", Spectate: true}); err != nil {
+		t.Fatalf("Expected a spectator to get into a locked room, got %v", err)
+	}
+
+	state := askState(t, ga)
+	if len(state.Players) != 2 || state.Spectators != 1 || state.HostID != "p1" {
+		t.Fatalf("Expected 2 players and 1 spectator, got %d and %d", len(state.Players), state.Spectators)
+	}
+
+	// Charades: the spectator is never the actor and isn't waited on
+	ga.Send(NextGameMsg{PlayerID: "p1", Game: "charades"})
+	ga.Send(NextGameMsg{PlayerID: "p1"})
+	ga.Send(NextGameMsg{PlayerID: "p2"})
+	if state := askState(t, ga); state.State != "playing" {
+		t.Fatalf("Expected spectators not to block the ready check, got '%s'", state.State)
+	}
+	ga.mu.Lock()
+	if actor := ga.game.(ActorGame).GetActor(); actor == "s1" {
+		t.Error("Expected the spectator never to be the actor")
+	}
+	ga.mu.Unlock()
+
+	// Claude's Game: spectators can't submit, vote or be voted for
+	ga.mu.Lock()
+	ga.currentGame = "claudesgame"
+	ga.state = "voting"
+	ga.votes = make(map[string]string)
+	ga.mu.Unlock()
+
+	ga.Send(VoteMsg{PlayerID: "s1", VotedForID: "p2"})
+	ga.Send(VoteMsg{PlayerID: "p1", VotedForID: "s1"})
+	ga.Send(VoteMsg{PlayerID: "p1", VotedForID: "p2"})
+	if state := askState(t, ga); state.State != "voting" {
+		t.Fatalf("Expected to wait for the second player's vote, got '%s'", state.State)
+	}
+	ga.Send(VoteMsg{PlayerID: "p2", Abstain: true})
+	if state := askState(t, ga); state.State != "finished" {
+		t.Errorf("Expected voting to finish without the spectator, got '%s'", state.State)
+	}
+
+	ga.Send(PlayerDisconnectMsg{PlayerID: "s1"})
+	if state := askState(t, ga); state.Spectators != 0 {
+		t.Errorf("Expected a dropped spectator to be removed, got %d", state.Spectators)
+	}
+}
+
+func TestGameActorTeamPlay(t *testing.T) {
+	ga := NewGameActor("test-game")
+	ga.Start()
+	defer ga.Stop()
+
+	for _, id := range []string{"p1", "p2", "p3", "p4"} {
+		askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: id, PlayerName: id})
+	}
+	ga.Send(AssignTeamMsg{PlayerID: "p1", TargetID: "p2", Team: 1})
+	ga.Send(SetTeamsMsg{PlayerID: "p2", Count: 2}) // not the host
+	if state := askState(t, ga); state.Teams != nil {
+		t.Fatal("Expected teams to need team play on and the host")
+	}
+
+	ga.Send(SetTeamsMsg{PlayerID: "p1", Count: 2})
+	for id, team := range map[string]int{"p1": 1, "p2": 1, "p3": 2, "p4": 2} {
+		ga.Send(AssignTeamMsg{PlayerID: "p1", TargetID: id, Team: team})
+	}
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "p5", PlayerName: "p5"})
+	state := askState(t, ga)
+	if len(state.Teams) != 2 || state.Players["p2"].Team != 1 || state.Players["p5"].Team != 1 {
+		t.Fatalf("Expected assigned teams with the joiner on the smaller team, got %+v", state.Teams)
+	}
+	ga.Send(PlayerLeaveMsg{PlayerID: "p5"})
+
+	// Team 1 acts first, and only its guesses count
+	ga.Send(NextGameMsg{PlayerID: "p1", Game: "charades"})
+	for _, id := range []string{"p1", "p2", "p3", "p4"} {
+		ga.Send(NextGameMsg{PlayerID: id})
+	}
+	askState(t, ga)
+	ga.mu.Lock()
+	charades := ga.game.(*Charades)
+	actor, topic := charades.GetActor(), charades.topic
+	ga.mu.Unlock()
+	if actor != "p1" {
+		t.Fatalf("Expected p1 to act first, got %s", actor)
+	}
+
+	ga.Send(SubmitWordMsg{PlayerID: "p3", Word: topic})
+	if state := askState(t, ga); state.State != "playing" {
+		t.Fatalf("Expected the other team's guess not to count, got '%s'", state.State)
+	}
+	ga.Send(SubmitWordMsg{PlayerID: "p2", Word: topic})
+	state = askState(t, ga)
+	if state.State != "finished" || state.Players["p2"].Score != 3 || state.Teams[0].Score != 3 {
+		t.Fatalf("Expected p2 to score 3 for team 1, got %+v", state.Teams)
+	}
+
+	// Votes can't go to your own team
+	ga.mu.Lock()
+	ga.currentGame = "claudesgame"
+	ga.state = "voting"
+	ga.votes = make(map[string]string)
+	ga.mu.Unlock()
+	for voter, candidate := range map[string]string{"p1": "p2", "p2": "p3", "p3": "p2", "p4": "p2"} {
+		ga.Send(VoteMsg{PlayerID: voter, VotedForID: candidate})
+	}
+	if state := askState(t, ga); state.State != "voting" {
+		t.Fatalf("Expected the own-team vote to be refused, got '%s'", state.State)
+	}
+	ga.Send(VoteMsg{PlayerID: "p1", VotedForID: "p4"})
+	state = askState(t, ga)
+	if state.State != "finished" || state.Teams[0].Score != 6 || state.Teams[1].Score != 0 {
+		t.Errorf("Expected p2's vote win to go to team 1, got %+v", state.Teams)
+	}
+	if member := state.Teams[0].Members[1]; member != (TeamMember{ID: "p2", Points: 6}) {
+		t.Errorf("Expected p2 to have won team 1's points, got %+v", member)
+	}
+}
+
+func TestGameActorMatchPodiumAndRematch(t *testing.T) {
+	ga := NewGameActor("test-game")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "p1", PlayerName: "Alice"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "p2", PlayerName: "Bob"})
+	ga.Send(UpdateSettingsMsg{PlayerID: "p1", Changes: json.RawMessage(`{"match_games": 1}`)})
+
+	ga.Send(NextGameMsg{PlayerID: "p1", Game: "claudesgame"})
+	ga.Send(NextGameMsg{PlayerID: "p1"})
+	ga.Send(NextGameMsg{PlayerID: "p2"})
+	ga.Send(SubmitWordMsg{PlayerID: "p1", Word: "a"})
+	ga.Send(SubmitWordMsg{PlayerID: "p2", Word: "b"})
+	ga.Send(VoteMsg{PlayerID: "p1", VotedForID: "p2"})
+	ga.Send(VoteMsg{PlayerID: "p2", Abstain: true})
+	if state := askState(t, ga); state.State != "finished" {
+		t.Fatalf("Expected the game to finish, got '%s'", state.State)
+	}
+
+	ga.Send(NextGameMsg{PlayerID: "p1"})
+	ga.Send(NextGameMsg{PlayerID: "p1"}) // nothing more to play on the podium
+	if state := askState(t, ga); state.State != "podium" {
+		t.Fatalf("Expected the podium after the match's last game, got '%s'", state.State)
+	}
+	ga.mu.Lock()
+	standings := ga.standings()
+	if len(ga.match) != 1 || len(ga.match[0].Winners) != 1 || ga.match[0].Winners[0] != "Bob" {
+		t.Errorf("Expected Bob's win in the match summary, got %+v", ga.match)
+	}
+	ga.mu.Unlock()
+	if standings[0].Name != "Bob" || standings[0].Place != 1 || standings[1].Place != 2 {
+		t.Errorf("Expected Bob first on the podium, got %+v", standings)
+	}
+
+	ga.Send(RematchMsg{PlayerID: "p2"}) // not the host
+	ga.Send(RematchMsg{PlayerID: "p1"})
+	state := askState(t, ga)
+	if state.State != "lobby" || state.Players["p2"].Score != 0 || len(state.Players) != 2 {
+		t.Errorf("Expected a rematch to reset scores in the lobby, got '%s' with %+v", state.State, state.Players["p2"])
+	}
+	ga.mu.Lock()
+	if ga.match != nil || ga.gamesPlayed != 0 {
+		t.Errorf("Expected a rematch to clear the match, got %d games", ga.gamesPlayed)
+	}
+
+	// A point target ends the match too, and tied scores share a place
+	ga.settings.MatchGames = 0
+	ga.settings.MatchPoints = 5
+	ga.players["p1"].Score = 5
+	ga.players["p2"].Score = 5
+	ga.state = "finished"
+	ga.mu.Unlock()
+
+	ga.Send(NextGameMsg{PlayerID: "p1"})
+	if state := askState(t, ga); state.State != "podium" {
+		t.Fatalf("Expected reaching the point target to end the match, got '%s'", state.State)
+	}
+	ga.mu.Lock()
+	standings = ga.standings()
+	ga.mu.Unlock()
+	if standings[0].Place != 1 || standings[1].Place != 1 {
+		t.Errorf("Expected a shared first place, got %+v", standings)
+	}
+}
+
+// startTestVote puts a room of players into a Claude's Game vote under the
+// given rules
+func startTestVote(t *testing.T, settings RoomSettings, ids ...string) *GameActor {
+	t.Helper()
+	ga := NewGameActor("vote-test")
+	ga.Start()
+	for _, id := range ids {
+		askJoin(t, ga, PlayerJoinMsg{GameID: "vote-test", PlayerID: id, PlayerName: id})
+	}
+	ga.mu.Lock()
+	ga.settings = settings
+	ga.currentGame = "claudesgame"
+	ga.game = NewClaudesGame(GameConfig{})
+	ga.startVoting()
+	ga.mu.Unlock()
+	return ga
+}
+
+func TestGameActorVotingRules(t *testing.T) {
+	settings := DefaultRoomSettings()
+	ga := startTestVote(t, settings, "p1", "p2", "p3")
+	defer ga.Stop()
+
+	// Self-votes and unknown players are refused; abstaining counts
+	ga.Send(VoteMsg{PlayerID: "p1", VotedForID: "p1"})
+	ga.Send(VoteMsg{PlayerID: "p2", VotedForID: "nobody"})
+	ga.Send(VoteMsg{PlayerID: "p3", Abstain: true})
+	askState(t, ga)
+	ga.mu.Lock()
+	if len(ga.votes) != 1 || ga.votes["p3"] != "" {
+		t.Errorf("Expected only the abstention to count, got %v", ga.votes)
+	}
+	ga.mu.Unlock()
+
+	// A tie splits the points by default, and the votes are revealed
+	ga.Send(VoteMsg{PlayerID: "p1", VotedForID: "p2"})
+	ga.Send(VoteMsg{PlayerID: "p2", VotedForID: "p1"})
+	state := askState(t, ga)
+	if state.State != "finished" || state.Players["p1"].Score != 1 || state.Players["p2"].Score != 1 {
+		t.Fatalf("Expected the tied players to split 3 points, got %+v and %+v", state.Players["p1"], state.Players["p2"])
+	}
+	ga.mu.Lock()
+	results := ga.voteResults
+	ga.mu.Unlock()
+	want := []VoteResult{{Voter: "p1", VotedFor: "p2"}, {Voter: "p2", VotedFor: "p1"}, {Voter: "p3"}}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("Expected vote breakdown %+v, got %+v", want, results)
+	}
+}
+
+func TestGameActorVotingTieBreaks(t *testing.T) {
+	settings := DefaultRoomSettings()
+	settings.TieBreak = TieRunoff
+	ga := startTestVote(t, settings, "p1", "p2", "p3")
+	defer ga.Stop()
+
+	ga.Send(VoteMsg{PlayerID: "p1", VotedForID: "p2"})
+	ga.Send(VoteMsg{PlayerID: "p2", VotedForID: "p1"})
+	ga.Send(VoteMsg{PlayerID: "p3", Abstain: true})
+	askState(t, ga)
+	ga.mu.Lock()
+	if ga.state != "voting" || !reflect.DeepEqual(ga.runoff, []string{"p1", "p2"}) {
+		t.Fatalf("Expected a runoff between p1 and p2, got %v in '%s'", ga.runoff, ga.state)
+	}
+	ga.mu.Unlock()
+
+	ga.Send(VoteMsg{PlayerID: "p1", VotedForID: "p3"}) // not in the runoff
+	ga.Send(VoteMsg{PlayerID: "p1", VotedForID: "p2"})
+	ga.Send(VoteMsg{PlayerID: "p2", Abstain: true})
+	ga.Send(VoteMsg{PlayerID: "p3", VotedForID: "p2"})
+	state := askState(t, ga)
+	if state.State != "finished" || state.Players["p2"].Score != 3 || state.Players["p1"].Score != 0 {
+		t.Errorf("Expected p2 to win the runoff, got %+v", state.Players["p2"])
+	}
+
+	settings.TieBreak = TieRandom
+	ga2 := startTestVote(t, settings, "p1", "p2")
+	defer ga2.Stop()
+	ga2.Send(VoteMsg{PlayerID: "p1", VotedForID: "p2"})
+	ga2.Send(VoteMsg{PlayerID: "p2", VotedForID: "p1"})
+	state = askState(t, ga2)
+	if score := state.Players["p1"].Score + state.Players["p2"].Score; state.State != "finished" || score != 3 {
+		t.Errorf("Expected one random winner to get 3 points, got %d in total", score)
+	}
+}
+
+func TestGameActorVotingDeadline(t *testing.T) {
+	settings := DefaultRoomSettings()
+	settings.VoteSeconds = MinTimerSeconds
+	ga := NewGameActor("vote-test")
+	ga.tickInterval = time.Millisecond
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "vote-test", PlayerID: "p1", PlayerName: "p1"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "vote-test", PlayerID: "p2", PlayerName: "p2"})
+	ga.mu.Lock()
+	ga.settings = settings
+	ga.currentGame = "claudesgame"
+	ga.game = NewClaudesGame(GameConfig{})
+	ga.startVoting()
+	ga.mu.Unlock()
+
+	// p2 never votes; the deadline closes the vote without them
+	ga.Send(VoteMsg{PlayerID: "p1", VotedForID: "p2"})
+	time.Sleep(200 * time.Millisecond)
+	state := askState(t, ga)
+	if state.State != "finished" || state.Players["p2"].Score != 3 {
+		t.Errorf("Expected the deadline to close the vote, got '%s'", state.State)
+	}
+}
+
+func TestGameActorContentPacks(t *testing.T) {
+	dir := t.TempDir()
+	writePack(t, dir, "movies.yaml", "name: movies\ncharade_topics: [Jaws]\n")
+	lib, err := LoadContentLibrary(dir)
+	if err != nil {
+		t.Fatalf("LoadContentLibrary failed: %v", err)
+	}
+	defer func(previous *ContentLibrary) { contentPacks = previous }(contentPacks)
+	contentPacks = lib
+
+	ga := NewGameActor("test-game")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "p1", PlayerName: "Alice"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "p2", PlayerName: "Bob"})
+	ga.Send(SetPacksMsg{PlayerID: "p1", Packs: []string{"movies", "nope"}})
+	askState(t, ga)
+	ga.mu.Lock()
+	if ga.packs != nil {
+		t.Errorf("Expected an unknown pack to be refused, got %v", ga.packs)
+	}
+	ga.mu.Unlock()
+
+	ga.Send(SetPacksMsg{PlayerID: "p1", Packs: []string{"movies"}})
+	ga.Send(NextGameMsg{PlayerID: "p1", Game: "charades"})
+	askState(t, ga)
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+	if topic := ga.pending.(*Charades).topic; topic != "Jaws" {
+		t.Errorf("Expected the movies pack's only topic, got %q", topic)
+	}
+}
+
+func TestGameActorRoomTemplates(t *testing.T) {
+	ga := NewGameActor("test-game")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "p1", PlayerName: "Alice"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "p2", PlayerName: "Bob"})
+	ga.Send(AddTemplateMsg{PlayerID: "p2", Template: MadLibTemplate{Template: "A {noun"}})
+	ga.Send(AddTemplateMsg{PlayerID: "p2", Template: MadLibTemplate{Template: "Bob's {noun} can {verb}"}})
+	ga.Send(ClearTemplatesMsg{PlayerID: "p2"}) // only the host can clear
+	askState(t, ga)
+	ga.mu.Lock()
+	if len(ga.templates) != 1 || !reflect.DeepEqual(ga.templates[0].Prompts, []string{"noun", "verb"}) {
+		t.Fatalf("Expected the valid template with its prompts filled in, got %+v", ga.templates)
+	}
+	ga.mu.Unlock()
+
+	ga.Send(NextGameMsg{PlayerID: "p1", Game: "madlibs"})
+	askState(t, ga)
+	ga.mu.Lock()
+	if template := ga.pending.(*MadLib).Template; template != "Bob's {noun} can {verb}" {
+		t.Errorf("Expected the room's own template, got %q", template)
+	}
+	ga.mu.Unlock()
+
+	ga.Send(ClearTemplatesMsg{PlayerID: "p1"})
+	askState(t, ga)
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+	if ga.templates != nil {
+		t.Errorf("Expected the host to clear the templates, got %+v", ga.templates)
+	}
+}
+
+func TestGameActorIgnoredSubmissionsDontScore(t *testing.T) {
+	ga := NewGameActor("test-game")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "p1", PlayerName: "Alice"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "p2", PlayerName: "Bob"})
+	ga.Send(NextGameMsg{PlayerID: "p1", Game: "claudesgame"})
+	ga.Send(NextGameMsg{PlayerID: "p1"})
+	ga.Send(NextGameMsg{PlayerID: "p2"})
+
+	// Claude's Game keeps only the first answer, so the rest score nothing
+	for i := 0; i < 3; i++ {
+		ga.Send(SubmitWordMsg{PlayerID: "p1", Word: "again"})
+	}
+	state := askState(t, ga)
+	if want := DefaultRoomSettings().SubmissionPoints; state.Players["p1"].Score != want {
+		t.Errorf("Expected one submission's %d points, got %d", want, state.Players["p1"].Score)
+	}
+
+	// A Mad Libs word without a claimed slot is thrown away too
+	ga.mu.Lock()
+	ga.currentGame = "madlibs"
+	ga.game = NewMadLib(GameConfig{})
+	ga.mu.Unlock()
+	ga.Send(SubmitWordMsg{PlayerID: "p2", Word: "unclaimed"})
+	if state := askState(t, ga); state.Players["p2"].Score != 0 {
+		t.Errorf("Expected an unclaimed word not to score, got %d", state.Players["p2"].Score)
+	}
+}
+
+func TestGameActorVoteClosesWhenLastVoterGoes(t *testing.T) {
+	for _, leave := range []ActorMessage{PlayerLeaveMsg{PlayerID: "p3"}, PlayerDisconnectMsg{PlayerID: "p3"}} {
+		ga := NewGameActor("test-game")
+		ga.Start()
+
+		for _, id := range []string{"p1", "p2", "p3"} {
+			askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: id, PlayerName: id})
+		}
+		ga.Send(NextGameMsg{PlayerID: "p1", Game: "claudesgame"})
+		for _, id := range []string{"p1", "p2", "p3"} {
+			ga.Send(NextGameMsg{PlayerID: id})
+		}
+		for _, id := range []string{"p1", "p2", "p3"} {
+			ga.Send(SubmitWordMsg{PlayerID: id, Word: "answer " + id})
+		}
+		ga.Send(VoteMsg{PlayerID: "p1", VotedForID: "p2"})
+		ga.Send(VoteMsg{PlayerID: "p2", VotedForID: "p1"})
+		if state := askState(t, ga); state.State != "voting" {
+			t.Fatalf("Expected the vote to wait for p3, got '%s'", state.State)
+		}
+
+		// Everyone still here has voted, so there's no deadline to wait for
+		ga.Send(leave)
+		if state := askState(t, ga); state.State != "finished" {
+			t.Errorf("Expected the vote to close after %T, got '%s'", leave, state.State)
+		}
+		ga.Stop()
+	}
+}
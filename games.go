@@ -1,12 +1,54 @@
 package main
 
-import (
-	"math/rand"
-	"time"
-)
+import "math/rand"
 
 func init() {
-	rand.Seed(time.Now().UnixNano())
+	RegisterGame(GameDescriptor{
+		ID:         "charades",
+		Name:       "Charades",
+		MinPlayers: 2, // Needs 1 actor + at least 1 guesser
+		NeedsActor: true,
+		Scoring:    ScoreWinner,
+		New:        func(c GameConfig) GameType { return NewCharades(c) },
+	})
+	RegisterGame(GameDescriptor{
+		ID:          "claudesgame",
+		Name:        "Claude's Game",
+		NeedsVoting: true,
+		Scoring:     ScorePerSubmission,
+		New:         func(c GameConfig) GameType { return NewClaudesGame(c) },
+	})
+	RegisterGame(GameDescriptor{
+		ID:           "firsttofind",
+		Name:         "First to Find",
+		NeedsVoting:  true,
+		TimerSeconds: 30,
+		Scoring:      ScoreNone,
+		New:          func(c GameConfig) GameType { return NewFirstToFind(c) },
+	})
+	RegisterGame(GameDescriptor{
+		ID:         "imitations",
+		Name:       "Imitations",
+		MinPlayers: 2, // Needs 1 actor + at least 1 guesser
+		NeedsActor: true,
+		Scoring:    ScoreWinner,
+		New:        func(c GameConfig) GameType { return NewImitations(c) },
+	})
+	RegisterGame(GameDescriptor{
+		ID:           "blankestblank",
+		Name:         "Find the Blankest Blank",
+		NeedsVoting:  true,
+		TimerSeconds: 30,
+		Scoring:      ScoreNone,
+		New:          func(c GameConfig) GameType { return NewBlankestBlank(c) },
+	})
+	RegisterGame(GameDescriptor{
+		ID:          "youlaughyoulose",
+		Name:        "You Laugh You Lose",
+		NeedsVoting: true,
+		Scoring:     ScoreNone,
+		New:         func(c GameConfig) GameType { return NewYouLaughYouLose(c) },
+	})
 }
 
 // Game interface for all games
@@ -22,68 +64,120 @@ type GameType interface {
 	HasTimer() bool
 	GetTimeRemaining() int
 	DecrementTimer()
+	// PlayerView personalizes the shared view for one player. It receives a
+	// copy of the room's view and returns what that player should see.
+	PlayerView(view PlayerView, playerID string, role PlayerRole) PlayerView
 }
 
-// Game registry
-var AllGames = []string{
-	"madlibs",
-	"charades",
-	"claudesgame",
-	"firsttofind",
-	"imitations",
-	"blankestblank",
-	"youlaughyoulose",
-}
-
-func CreateGame(gameType string) GameType {
-	switch gameType {
-	case "madlibs":
-		return NewMadLib()
-	case "charades":
-		return NewCharades()
-	case "claudesgame":
-		return NewClaudesGame()
-	case "firsttofind":
-		return NewFirstToFind()
-	case "imitations":
-		return NewImitations()
-	case "blankestblank":
-		return NewBlankestBlank()
-	case "youlaughyoulose":
-		return NewYouLaughYouLose()
-	default:
-		return NewMadLib()
-	}
-}
-
-func RandomGameType() string {
-	return AllGames[rand.Intn(len(AllGames))]
-}
-
-// MinPlayersRequired returns the minimum number of players required for a game
-func MinPlayersRequired(gameType string) int {
-	switch gameType {
-	case "imitations", "charades":
-		return 2 // Needs 1 actor + at least 1 guesser
-	default:
-		return 1 // Most games work with 1 player
-	}
-}
-
-// RandomGameTypeForPlayers returns a random game type appropriate for the player count
-func RandomGameTypeForPlayers(playerCount int) string {
-	validGames := []string{}
-	for _, game := range AllGames {
-		if MinPlayersRequired(game) <= playerCount {
-			validGames = append(validGames, game)
-		}
+// PlayerRole is a player's role in the room, as opposed to their part in a game
+type PlayerRole string
+
+const (
+	RolePlayer PlayerRole = "player"
+	// RoleSpectator watches without playing. Views for spectators must
+	// not give away anything hidden from the guessers, since spectators
+	// are often streaming the room.
+	RoleSpectator PlayerRole = "spectator"
+)
+
+// PlayerView is what a single player sees for the current room state
+type PlayerView struct {
+	State             string // room state the view was built for
+	Title             string
+	Instructions      string
+	RoundInstructions string
+	NeedsInput        bool
+	Extra             map[string]interface{} // game-specific fields merged into the state
+}
+
+// ActorGame is implemented by games where one player acts and the rest guess
+type ActorGame interface {
+	SetActor(actorID string)
+	GetActor() string
+}
+
+// WinnerGame is implemented by games won by a single submission
+type WinnerGame interface {
+	GetWinner() string
+	SetWinnerName(name string)
+}
+
+// TimedGame is implemented by games whose rounds run on the server's timer
+type TimedGame interface {
+	StartTimer(seconds int)
+}
+
+// PlayerCountGame is implemented by games that need to know how many are playing
+type PlayerCountGame interface {
+	SetNumPlayers(n int)
+}
+
+// SlotGame is implemented by games where players claim slots to fill in
+type SlotGame interface {
+	ClaimSlotForPlayer(playerID string) bool
+	// ReleaseSlotsForPlayer frees a departed player's unfilled slots
+	ReleaseSlotsForPlayer(playerID string)
+	// TickClaims runs claim timeouts on the round ticker, reporting
+	// whether any are counting down
+	TickClaims() bool
+}
+
+// ScoredGame is implemented by games that work out their own points
+type ScoredGame interface {
+	// TakePoints returns the points earned since the last call
+	TakePoints() map[string]int
+}
+
+// Contribution is one player's piece of a shared result, like a word in a
+// Mad Lib
+type Contribution struct {
+	ID       string `json:"id"`
+	Prompt   string `json:"prompt"`
+	Word     string `json:"word"`
+	AuthorID string `json:"author_id,omitempty"` // left out while votes are anonymous
+	Mine     bool   `json:"mine,omitempty"`      // the viewing player wrote it
+}
+
+// OwnResultGame is implemented by games whose result the page shows on its
+// own, like a Mad Libs story, so the finished view doesn't repeat it
+type OwnResultGame interface {
+	ShowsOwnResult() bool
+}
+
+// AcceptingGame is implemented by games that can ignore a submission, like
+// a second answer from the same player. Submit works like SubmitAnswer and
+// also reports whether the answer was kept, so only kept ones score.
+type AcceptingGame interface {
+	Submit(playerID, answer string) (accepted, complete bool)
+}
+
+// ContributionGame is implemented by games built from players' pieces, so
+// the room can vote for the best piece afterwards
+type ContributionGame interface {
+	Contributions() []Contribution
+}
+
+// CreateGame creates a new instance of a registered game type, or returns
+// nil if no game is registered under that ID
+func CreateGame(gameType string, config GameConfig) GameType {
+	if desc, ok := LookupGame(gameType); ok {
+		return desc.New(config)
 	}
+	return nil
+}
 
-	if len(validGames) == 0 {
-		return "madlibs" // fallback
+// GameFitsPlayers reports whether a game can be played with this many players
+func GameFitsPlayers(gameType string, playerCount int) bool {
+	desc, ok := LookupGame(gameType)
+	if !ok {
+		return false
 	}
+	return desc.MinPlayers <= playerCount && (desc.MaxPlayers == 0 || playerCount <= desc.MaxPlayers)
+}
 
-	return validGames[rand.Intn(len(validGames))]
+// pickOne returns a random entry of list
+func pickOne(rng *rand.Rand, list []string) string {
+	return list[rng.Intn(len(list))]
 }
 
 // Charades game
@@ -102,9 +196,9 @@ var charadeTopics = []string{
 	"cooking pasta", "riding a bicycle", "swimming",
 }
 
-func NewCharades() *Charades {
+func NewCharades(config GameConfig) *Charades {
 	return &Charades{
-		topic:       charadeTopics[rand.Intn(len(charadeTopics))],
+		topic:       pickOne(config.random(), config.content().CharadeTopics),
 		guessed:     false,
 		submissions: make(map[string]string),
 	}
@@ -162,6 +256,33 @@ func (c *Charades) HasTimer() bool        { return false }
 func (c *Charades) GetTimeRemaining() int { return 0 }
 func (c *Charades) DecrementTimer()       {}
 
+// PlayerView shows the topic to the actor only
+func (c *Charades) PlayerView(view PlayerView, playerID string, role PlayerRole) PlayerView {
+	if view.State != "playing" {
+		return view
+	}
+
+	if role == RoleSpectator {
+		view.Title = "Guess along: what's being acted out?"
+		view.Instructions = ""
+		view.RoundInstructions = ""
+		view.NeedsInput = false
+	} else if playerID == c.actorID {
+		// Actor gets told what to act out
+		view.Title = "Act out: " + c.topic + "!"
+		view.Instructions = ""
+		view.RoundInstructions = ""
+		view.NeedsInput = false // Actor doesn't submit anything
+	} else {
+		// Guessers get the normal prompt
+		view.Title = "Guess what's being acted out!"
+		view.Instructions = "Enter your answer:"
+		view.RoundInstructions = ""
+		view.NeedsInput = true
+	}
+	return view
+}
+
 // Claude's Game
 type ClaudesGame struct {
 	word1       string
@@ -175,11 +296,12 @@ var claudesGameWords = []string{
 	"volcano", "penguin", "telescope", "sandcastle", "lightning",
 }
 
-func NewClaudesGame() *ClaudesGame {
-	shuffled := rand.Perm(len(claudesGameWords))
+func NewClaudesGame(config GameConfig) *ClaudesGame {
+	words := config.content().ClaudesGameWords
+	shuffled := config.random().Perm(len(words))
 	return &ClaudesGame{
-		word1:       claudesGameWords[shuffled[0]],
-		word2:       claudesGameWords[shuffled[1]],
+		word1:       words[shuffled[0]],
+		word2:       words[shuffled[1]],
 		submissions: make(map[string]string),
 		numPlayers:  1, // will be updated when first player submits
 	}
@@ -193,13 +315,20 @@ func (c *ClaudesGame) GetPrompt() string {
 	return "How are " + c.word1 + " and " + c.word2 + " connected?"
 }
 func (c *ClaudesGame) SubmitAnswer(playerID, answer string) bool {
-	// Only accept one answer per player
+	_, complete := c.Submit(playerID, answer)
+	return complete
+}
+
+// Submit keeps a player's first answer and ignores any after it
+func (c *ClaudesGame) Submit(playerID, answer string) (accepted, complete bool) {
 	if _, exists := c.submissions[playerID]; !exists {
 		c.submissions[playerID] = answer
+		accepted = true
 	}
 	// Complete when all players have submitted (need at least 1)
-	return len(c.submissions) >= c.numPlayers && c.numPlayers > 0
+	return accepted, c.IsComplete()
 }
+func (c *ClaudesGame) SetNumPlayers(n int) { c.numPlayers = n }
 func (c *ClaudesGame) IsComplete() bool {
 	return len(c.submissions) >= c.numPlayers && c.numPlayers > 0
 }
@@ -213,6 +342,9 @@ func (c *ClaudesGame) GetResult() string {
 func (c *ClaudesGame) HasTimer() bool        { return false }
 func (c *ClaudesGame) GetTimeRemaining() int { return 0 }
 func (c *ClaudesGame) DecrementTimer()       {}
+func (c *ClaudesGame) PlayerView(view PlayerView, playerID string, role PlayerRole) PlayerView {
+	return view
+}
 
 // First to Find
 type FirstToFind struct {
@@ -231,10 +363,10 @@ var itemsToFind = []string{
 	"compact disc",
 }
 
-func NewFirstToFind() *FirstToFind {
+func NewFirstToFind(config GameConfig) *FirstToFind {
 	return &FirstToFind{
-		item:          itemsToFind[rand.Intn(len(itemsToFind))],
-		timeRemaining: 30,
+		item:          pickOne(config.random(), config.content().ItemsToFind),
+		timeRemaining: config.TimerSeconds,
 		timerActive:   false, // Timer starts when players click "Start"
 	}
 }
@@ -247,11 +379,7 @@ func (f *FirstToFind) GetID() string     { return "firsttofind" }
 func (f *FirstToFind) NeedsInput() bool  { return false }
 func (f *FirstToFind) GetPrompt() string { return "First to show a " + f.item + " wins!" }
 func (f *FirstToFind) SubmitAnswer(playerID, answer string) bool {
-	// Timer completion triggers voting
-	if answer == "timer_complete" {
-		f.timerActive = false
-		return true
-	}
+	// Only the server-side timer can end the round
 	return false
 }
 func (f *FirstToFind) IsComplete() bool  { return !f.timerActive }
@@ -260,6 +388,10 @@ func (f *FirstToFind) HasTimer() bool    { return true }
 func (f *FirstToFind) GetTimeRemaining() int {
 	return f.timeRemaining
 }
+func (f *FirstToFind) StartTimer(seconds int) {
+	f.timeRemaining = seconds
+	f.timerActive = true
+}
 func (f *FirstToFind) DecrementTimer() {
 	if f.timeRemaining > 0 {
 		f.timeRemaining--
@@ -268,6 +400,9 @@ func (f *FirstToFind) DecrementTimer() {
 		f.timerActive = false
 	}
 }
+func (f *FirstToFind) PlayerView(view PlayerView, playerID string, role PlayerRole) PlayerView {
+	return view
+}
 
 // Imitations
 type Imitations struct {
@@ -284,9 +419,9 @@ var peopleToImitate = []string{
 	"Barack Obama", "Morgan Freeman", "Donald Duck",
 }
 
-func NewImitations() *Imitations {
+func NewImitations(config GameConfig) *Imitations {
 	return &Imitations{
-		person:      peopleToImitate[rand.Intn(len(peopleToImitate))],
+		person:      pickOne(config.random(), config.content().PeopleToImitate),
 		guessed:     false,
 		submissions: make(map[string]string),
 	}
@@ -470,6 +605,33 @@ func (i *Imitations) HasTimer() bool        { return false }
 func (i *Imitations) GetTimeRemaining() int { return 0 }
 func (i *Imitations) DecrementTimer()       {}
 
+// PlayerView tells the actor who to imitate
+func (i *Imitations) PlayerView(view PlayerView, playerID string, role PlayerRole) PlayerView {
+	if view.State != "playing" {
+		return view
+	}
+
+	if role == RoleSpectator {
+		view.Title = "Guess along: who's being imitated?"
+		view.Instructions = ""
+		view.RoundInstructions = ""
+		view.NeedsInput = false
+	} else if playerID == i.actorID {
+		// Actor gets told who to imitate
+		view.Title = "Imitate " + i.person + "!"
+		view.Instructions = ""
+		view.RoundInstructions = ""
+		view.NeedsInput = false // Actor doesn't submit anything
+	} else {
+		// Guessers get the normal prompt
+		view.Title = "Guess who's being imitated!"
+		view.Instructions = "Enter your answer:"
+		view.RoundInstructions = ""
+		view.NeedsInput = true
+	}
+	return view
+}
+
 // Find the Blankest Blank
 type BlankestBlank struct {
 	adjective     string
@@ -488,11 +650,12 @@ var nouns = []string{
 	"coin", "book", "hat",
 }
 
-func NewBlankestBlank() *BlankestBlank {
+func NewBlankestBlank(config GameConfig) *BlankestBlank {
+	rng := config.random()
 	return &BlankestBlank{
-		adjective:     adjectives[rand.Intn(len(adjectives))],
-		noun:          nouns[rand.Intn(len(nouns))],
-		timeRemaining: 30,
+		adjective:     pickOne(rng, config.content().Adjectives),
+		noun:          pickOne(rng, config.content().Nouns),
+		timeRemaining: config.TimerSeconds,
 		timerActive:   false, // Timer starts when players click "Start"
 	}
 }
@@ -506,11 +669,7 @@ func (b *BlankestBlank) NeedsInput() bool  { return false }
 func (b *BlankestBlank) GetPrompt() string { return "Find the " + b.adjective + " " + b.noun + "!" }
 func (b *BlankestBlank) GetNoun() string   { return b.noun }
 func (b *BlankestBlank) SubmitAnswer(playerID, answer string) bool {
-	// Timer completion triggers voting
-	if answer == "timer_complete" {
-		b.timerActive = false
-		return true
-	}
+	// Only the server-side timer can end the round
 	return false
 }
 func (b *BlankestBlank) IsComplete() bool { return !b.timerActive }
@@ -521,6 +680,10 @@ func (b *BlankestBlank) HasTimer() bool { return true }
 func (b *BlankestBlank) GetTimeRemaining() int {
 	return b.timeRemaining
 }
+func (b *BlankestBlank) StartTimer(seconds int) {
+	b.timeRemaining = seconds
+	b.timerActive = true
+}
 func (b *BlankestBlank) DecrementTimer() {
 	if b.timeRemaining > 0 {
 		b.timeRemaining--
@@ -530,6 +693,17 @@ func (b *BlankestBlank) DecrementTimer() {
 	}
 }
 
+// PlayerView uses the prompt as the instructions title
+func (b *BlankestBlank) PlayerView(view PlayerView, playerID string, role PlayerRole) PlayerView {
+	if view.State == "instructions" {
+		view.Title = b.GetPrompt()
+		// The instructions already say "Click next when everyone has their [item]"
+		// so we don't need redundant round instructions
+		view.RoundInstructions = ""
+	}
+	return view
+}
+
 // You Laugh You Lose
 type YouLaughYouLose struct {
 	videoID  string
@@ -542,10 +716,14 @@ var funnyVideos = []string{
 	"Veg63B8ofnQ", "tjiouAv0-Gk", "oaTxUeZWC4M", "BKInDainD5M",
 }
 
-func NewYouLaughYouLose() *YouLaughYouLose {
+func NewYouLaughYouLose(config GameConfig) *YouLaughYouLose {
+	duration := config.TimerSeconds
+	if duration == 0 {
+		duration = 90 // the video ends the round, not the server's timer
+	}
 	return &YouLaughYouLose{
-		videoID:  funnyVideos[rand.Intn(len(funnyVideos))],
-		duration: 90,
+		videoID:  pickOne(config.random(), config.content().FunnyVideos),
+		duration: duration,
 		elapsed:  0,
 	}
 }
@@ -573,3 +751,9 @@ func (y *YouLaughYouLose) GetTimeRemaining() int {
 	return 0
 }
 func (y *YouLaughYouLose) DecrementTimer() {}
+
+// PlayerView sends the video to play
+func (y *YouLaughYouLose) PlayerView(view PlayerView, playerID string, role PlayerRole) PlayerView {
+	view.Extra["youtube_video_id"] = y.videoID
+	return view
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestCharadesPlayerViewHidesTopic(t *testing.T) {
+	c := NewCharades(GameConfig{})
+	c.SetActor("actor")
+
+	base := PlayerView{State: "playing", Title: c.GetPrompt(), NeedsInput: true, Extra: map[string]interface{}{}}
+
+	actorView := c.PlayerView(base, "actor", RolePlayer)
+	if actorView.Title != "Act out: "+c.GetTopic()+"!" {
+		t.Errorf("Expected actor to see the topic, got '%s'", actorView.Title)
+	}
+	if actorView.NeedsInput {
+		t.Error("Expected actor not to need input")
+	}
+
+	guesserView := c.PlayerView(base, "guesser", RolePlayer)
+	if guesserView.Title == actorView.Title {
+		t.Error("Guesser should not see the topic")
+	}
+	if !guesserView.NeedsInput {
+		t.Error("Expected guesser to need input")
+	}
+}
+
+func TestSpectatorViewsHideSecrets(t *testing.T) {
+	c := NewCharades(GameConfig{})
+	c.SetActor("actor")
+	i := NewImitations(GameConfig{})
+	i.SetActor("actor")
+
+	base := PlayerView{State: "playing", NeedsInput: true, Extra: map[string]interface{}{}}
+	for secret, view := range map[string]PlayerView{
+		c.GetTopic(): c.PlayerView(base, "watcher", RoleSpectator),
+		i.person:     i.PlayerView(base, "watcher", RoleSpectator),
+	} {
+		if strings.Contains(view.Title+view.Instructions+view.RoundInstructions, secret) {
+			t.Errorf("Spectator view gives away %q: %+v", secret, view)
+		}
+		if view.NeedsInput {
+			t.Error("Expected spectators not to be asked for input")
+		}
+	}
+}
+
+func TestImitationsPlayerViewOnlyWhilePlaying(t *testing.T) {
+	i := NewImitations(GameConfig{})
+	i.SetActor("actor")
+
+	base := PlayerView{State: "instructions", Title: i.GetName(), Extra: map[string]interface{}{}}
+
+	view := i.PlayerView(base, "actor", RolePlayer)
+	if view.Title != i.GetName() {
+		t.Errorf("Expected instructions view to be unchanged, got '%s'", view.Title)
+	}
+}
+
+func TestGamesAreReproducibleFromSeed(t *testing.T) {
+	for _, id := range GameIDs() {
+		desc, _ := LookupGame(id)
+		first := desc.New(GameConfig{Rand: rand.New(rand.NewSource(7))})
+		second := desc.New(GameConfig{Rand: rand.New(rand.NewSource(7))})
+		if first.GetInstructions() != second.GetInstructions() || first.GetPrompt() != second.GetPrompt() {
+			t.Errorf("Game %s differs between two games from the same seed", id)
+		}
+	}
+
+	// A different seed gets a different topic at least some of the time
+	topics := make(map[string]bool)
+	for seed := int64(0); seed < 20; seed++ {
+		topics[NewCharades(GameConfig{Rand: rand.New(rand.NewSource(seed))}).topic] = true
+	}
+	if len(topics) < 2 {
+		t.Errorf("Expected seeds to pick different topics, got %v", topics)
+	}
+}
@@ -2,6 +2,13 @@ module github.com/nelsonblaha/videogames2
 
 go 1.21
 
-require github.com/gorilla/websocket v1.5.1
+require (
+	github.com/gorilla/websocket v1.5.1
+	go.etcd.io/bbolt v1.3.8
+	gopkg.in/yaml.v3 v3.0.1
+)
 
-require golang.org/x/net v0.17.0 // indirect
+require (
+	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+)
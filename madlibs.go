@@ -1,19 +1,23 @@
 package main
 
 import (
-	"math/rand"
-	"time"
+	"sort"
+	"strconv"
 )
 
 type MadLib struct {
 	Template      string
 	Prompts       []string
 	Words         []string
+	Authors       []string          // player who filled each slot
 	claimedBy     []string          // tracks which player has claimed each slot ("" = unclaimed)
 	playerPrompts map[string]int    // tracks which prompt index each player is currently on
+	claimSeconds  int               // how long a player can hold a slot, 0 for no limit
+	claimLeft     []int             // seconds left on each claimed slot
+	anonymous     bool              // hide who wrote each word until the vote is over
 }
 
-var madLibTemplates = []MadLib{
+var madLibTemplates = []MadLibTemplate{
 	{
 		Template: "Once upon a time, there was a {adjective} {noun} who loved to {verb} in the {place}. One day, they met a {adjective} {animal} who said '{exclamation}!'",
 		Prompts:  []string{"adjective", "noun", "verb", "place", "adjective", "animal", "exclamation"},
@@ -37,42 +41,61 @@ var madLibTemplates = []MadLib{
 }
 
 func init() {
-	rand.Seed(time.Now().UnixNano())
+	RegisterGame(GameDescriptor{
+		ID:      "madlibs",
+		Name:    "Mad Libs",
+		Scoring: ScorePerSubmission,
+		New:     func(c GameConfig) GameType { return NewMadLib(c) },
+	})
 }
 
-func NewMadLib() *MadLib {
-	template := madLibTemplates[rand.Intn(len(madLibTemplates))]
+func NewMadLib(config GameConfig) *MadLib {
+	templates := config.content().MadLibTemplates
+	template := templates[config.random().Intn(len(templates))]
 	numPrompts := len(template.Prompts)
 	return &MadLib{
 		Template:      template.Template,
 		Prompts:       template.Prompts,
 		Words:         make([]string, numPrompts),
+		Authors:       make([]string, numPrompts),
 		claimedBy:     make([]string, numPrompts), // all start as ""
 		playerPrompts: make(map[string]int),
+		claimSeconds:  config.ClaimSeconds,
+		claimLeft:     make([]int, numPrompts),
+		anonymous:     config.AnonymousWords,
 	}
 }
 
 // AddWordForPlayer fills the slot claimed by this player
 func (m *MadLib) AddWordForPlayer(playerID, word string) bool {
+	_, complete := m.Submit(playerID, word)
+	return complete
+}
+
+// Submit fills the slot claimed by this player, reporting whether they had
+// one to fill
+func (m *MadLib) Submit(playerID, word string) (accepted, complete bool) {
 	// Find the slot this player has claimed
 	idx, exists := m.playerPrompts[playerID]
 	if !exists {
-		return m.IsComplete()
+		return false, m.IsComplete()
 	}
 
 	// Verify this slot is actually claimed by this player
 	if idx >= len(m.claimedBy) || m.claimedBy[idx] != playerID {
-		return m.IsComplete()
+		return false, m.IsComplete()
 	}
 
 	// Fill the slot
 	m.Words[idx] = word
+	if m.Authors != nil {
+		m.Authors[idx] = playerID
+	}
 
 	// Clear this player's claim and prompt them for next slot
 	m.claimNextSlotForPlayer(playerID)
 
-	// Return true if all words are now filled
-	return m.IsComplete()
+	return true, m.IsComplete()
 }
 
 // AddWord is kept for backward compatibility with the GameType interface
@@ -114,9 +137,7 @@ func (m *MadLib) claimNextSlotForPlayer(playerID string) int {
 	// Find first unclaimed slot
 	for i, claimer := range m.claimedBy {
 		if claimer == "" {
-			// Claim this slot for the player
-			m.claimedBy[i] = playerID
-			m.playerPrompts[playerID] = i
+			m.giveSlot(i, playerID)
 			return i
 		}
 	}
@@ -125,6 +146,75 @@ func (m *MadLib) claimNextSlotForPlayer(playerID string) int {
 	return -1
 }
 
+// giveSlot claims a slot for a player and starts its timeout
+func (m *MadLib) giveSlot(idx int, playerID string) {
+	m.claimedBy[idx] = playerID
+	m.playerPrompts[playerID] = idx
+	if m.claimLeft != nil {
+		m.claimLeft[idx] = m.claimSeconds
+	}
+}
+
+// waitingPlayers lists the players who asked for a slot when none were
+// free, in a fixed order so replays hand out slots the same way
+func (m *MadLib) waitingPlayers() []string {
+	var waiting []string
+	for id, idx := range m.playerPrompts {
+		if idx == -1 {
+			waiting = append(waiting, id)
+		}
+	}
+	sort.Strings(waiting)
+	return waiting
+}
+
+// ReleaseSlotsForPlayer frees the slot a player who left was filling in,
+// handing it to a waiting player if there is one. The words they already
+// gave stay in the story.
+func (m *MadLib) ReleaseSlotsForPlayer(playerID string) {
+	delete(m.playerPrompts, playerID)
+	for i, claimer := range m.claimedBy {
+		if claimer != playerID || m.Words[i] != "" {
+			continue
+		}
+		m.claimedBy[i] = ""
+		if waiting := m.waitingPlayers(); len(waiting) > 0 {
+			m.giveSlot(i, waiting[0])
+		}
+	}
+}
+
+// TickClaims counts down a second on every claimed slot. A slot whose time
+// runs out goes to a waiting player, and its idle claimer waits in turn.
+// With nobody waiting the claimer keeps it, since nobody else could fill it
+// sooner. Reports whether any claim is counting down.
+func (m *MadLib) TickClaims() bool {
+	if m.claimSeconds <= 0 || m.claimLeft == nil {
+		return false
+	}
+
+	counting := false
+	for i, claimer := range m.claimedBy {
+		if claimer == "" || m.Words[i] != "" {
+			continue
+		}
+		counting = true
+		m.claimLeft[i]--
+		if m.claimLeft[i] > 0 {
+			continue
+		}
+
+		waiting := m.waitingPlayers()
+		if len(waiting) == 0 {
+			m.claimLeft[i] = m.claimSeconds
+			continue
+		}
+		m.playerPrompts[claimer] = -1
+		m.giveSlot(i, waiting[0])
+	}
+	return counting
+}
+
 // findNextEmptySlot finds the next unfilled word slot (for backward compatibility)
 func (m *MadLib) findNextEmptySlot() int {
 	for i, w := range m.Words {
@@ -164,25 +254,26 @@ func (m *MadLib) IsComplete() bool {
 	return true
 }
 
+// GetStory fills the template's placeholders with the words, by position
 func (m *MadLib) GetStory() string {
-	story := m.Template
-	for i, word := range m.Words {
-		// Replace {prompt} with actual word
-		prompt := "{" + m.Prompts[i] + "}"
-		story = replaceFirst(story, prompt, word)
-	}
-	return story
+	return RenderTemplate(m.Template, m.Words)
 }
 
-func replaceFirst(s, old, new string) string {
-	i := 0
-	for j := 0; j <= len(s)-len(old); j++ {
-		if s[j:j+len(old)] == old {
-			return s[:j] + new + s[j+len(old):]
+// Contributions lists the filled-in words with who wrote them. IDs are
+// slot numbers, counting from 1.
+func (m *MadLib) Contributions() []Contribution {
+	contributions := []Contribution{}
+	for i, word := range m.Words {
+		if word == "" {
+			continue
+		}
+		contribution := Contribution{ID: strconv.Itoa(i + 1), Prompt: m.Prompts[i], Word: word}
+		if m.Authors != nil {
+			contribution.AuthorID = m.Authors[i]
 		}
-		i++
+		contributions = append(contributions, contribution)
 	}
-	return s
+	return contributions
 }
 
 // Implement GameType interface
@@ -192,9 +283,62 @@ func (m *MadLib) GetID() string           { return "madlibs" }
 func (m *MadLib) NeedsInput() bool        { return true }
 func (m *MadLib) GetPrompt() string       { return m.CurrentPrompt() }
 func (m *MadLib) SubmitAnswer(playerID, answer string) bool {
-	return m.AddWord(answer)
+	return m.AddWordForPlayer(playerID, answer)
 }
 func (m *MadLib) GetResult() string       { return m.GetStory() }
+func (m *MadLib) ShowsOwnResult() bool    { return true }
 func (m *MadLib) HasTimer() bool          { return false }
 func (m *MadLib) GetTimeRemaining() int   { return 0 }
 func (m *MadLib) DecrementTimer()         {}
+
+// PlayerView gives each player the prompt for the slot they claimed
+func (m *MadLib) PlayerView(view PlayerView, playerID string, role PlayerRole) PlayerView {
+	switch view.State {
+	case "playing":
+		view.Extra["current_prompt"] = m.CurrentPrompt()
+		view.Extra["words_collected"] = len(m.Words) - countEmpty(m.Words)
+		view.Extra["total_words"] = len(m.Words)
+
+		// Players who haven't asked for a slot yet need to request one
+		_, requested := m.playerPrompts[playerID]
+		view.Extra["slot_requested"] = requested
+
+		// Each player gets their own personalized prompt
+		if role == RoleSpectator {
+			view.Title = "Filling in the blanks..."
+			view.Instructions = "The players are choosing words for the story"
+			view.RoundInstructions = ""
+			view.NeedsInput = false
+		} else if playerPrompt := m.GetPromptForPlayer(playerID); playerPrompt != "" {
+			view.Title = playerPrompt
+			view.Extra["current_prompt"] = playerPrompt
+			view.NeedsInput = true
+			if m.claimSeconds > 0 {
+				view.Extra["claim_seconds_left"] = m.claimLeft[m.playerPrompts[playerID]]
+			}
+		} else {
+			// No slots available - all slots are either claimed by others or filled
+			view.Title = "Please wait..."
+			view.Instructions = "All words are being filled by other players"
+			view.RoundInstructions = ""
+			view.NeedsInput = false
+		}
+
+	case "voting":
+		// Players vote for the funniest word, credited unless the room
+		// keeps authors secret until the vote is over
+		contributions := m.Contributions()
+		for i := range contributions {
+			contributions[i].Mine = role != RoleSpectator && contributions[i].AuthorID == playerID
+			if m.anonymous {
+				contributions[i].AuthorID = ""
+			}
+		}
+		view.Extra["contributions"] = contributions
+		view.RoundInstructions = "Vote for the funniest word!"
+
+	case "finished":
+		view.Extra["contributions"] = m.Contributions()
+	}
+	return view
+}
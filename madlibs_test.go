@@ -1,12 +1,13 @@
 package main
 
 import (
+	"reflect"
 	"testing"
 	"time"
 )
 
 func TestMadLibSlotReservation(t *testing.T) {
-	madlib := NewMadLib()
+	madlib := NewMadLib(GameConfig{})
 
 	// Player 1 claims first slot
 	claimed := madlib.ClaimSlotForPlayer("player1")
@@ -47,7 +48,7 @@ func TestMadLibSlotReservation(t *testing.T) {
 }
 
 func TestMadLibQueryDoesNotMutate(t *testing.T) {
-	madlib := NewMadLib()
+	madlib := NewMadLib(GameConfig{})
 
 	// Query before claiming - should return empty, not claim
 	prompt := madlib.GetPromptForPlayer("player1")
@@ -62,7 +63,7 @@ func TestMadLibQueryDoesNotMutate(t *testing.T) {
 }
 
 func TestMadLibSubmitAndAdvance(t *testing.T) {
-	madlib := NewMadLib()
+	madlib := NewMadLib(GameConfig{})
 
 	// Player claims and fills first slot
 	madlib.ClaimSlotForPlayer("player1")
@@ -100,7 +101,7 @@ func TestMadLibSubmitAndAdvance(t *testing.T) {
 }
 
 func TestMadLibAllSlotsClaimedNoMore(t *testing.T) {
-	madlib := NewMadLib()
+	madlib := NewMadLib(GameConfig{})
 	numSlots := len(madlib.Prompts)
 
 	// Claim all slots with different players
@@ -131,7 +132,7 @@ func TestMadLibAllSlotsClaimedNoMore(t *testing.T) {
 }
 
 func TestMadLibCompletionAllWordsFilled(t *testing.T) {
-	madlib := NewMadLib()
+	madlib := NewMadLib(GameConfig{})
 	numSlots := len(madlib.Prompts)
 
 	// Single player fills all slots
@@ -179,9 +180,7 @@ func TestMadLibActorRequestPrompt(t *testing.T) {
 	time.Sleep(50 * time.Millisecond)
 
 	// Verify we're in playing state
-	responseChan := make(chan *GameState, 1)
-	ga.Send(GetGameStateMsg{ResponseChan: responseChan})
-	state := <-responseChan
+	state := askState(t, ga)
 
 	if state.State != "playing" {
 		t.Fatalf("Expected 'playing' state, got '%s'", state.State)
@@ -190,7 +189,7 @@ func TestMadLibActorRequestPrompt(t *testing.T) {
 	// Force Mad Libs as the game (since it's random)
 	ga.mu.Lock()
 	ga.currentGame = "madlibs"
-	ga.game = NewMadLib()
+	ga.game = NewMadLib(GameConfig{})
 	ga.mu.Unlock()
 
 	// Send RequestPromptMsg
@@ -245,7 +244,7 @@ func TestMadLibActorMultiplePlayersNoDuplicateSlots(t *testing.T) {
 	ga.mu.Lock()
 	ga.currentGame = "madlibs"
 	ga.state = "playing"
-	ga.game = NewMadLib()
+	ga.game = NewMadLib(GameConfig{})
 	ga.mu.Unlock()
 
 	// Both players request prompts
@@ -290,7 +289,7 @@ func TestMadLibActorSubmitWord(t *testing.T) {
 	ga.mu.Lock()
 	ga.currentGame = "madlibs"
 	ga.state = "playing"
-	ga.game = NewMadLib()
+	ga.game = NewMadLib(GameConfig{})
 	ga.mu.Unlock()
 
 	// Request prompt and submit word
@@ -323,3 +322,211 @@ func TestMadLibActorSubmitWord(t *testing.T) {
 		t.Error("Expected player to advance to next slot")
 	}
 }
+
+// oneSlotMadLib is a Mad Lib with a single word to fill in
+func oneSlotMadLib(claimSeconds int) *MadLib {
+	return NewMadLib(GameConfig{
+		ClaimSeconds: claimSeconds,
+		Content:      &Content{MadLibTemplates: []MadLibTemplate{{Template: "A {noun}", Prompts: []string{"noun"}}}},
+	})
+}
+
+func TestMadLibReleaseSlotsForPlayer(t *testing.T) {
+	madlib := oneSlotMadLib(0)
+	madlib.ClaimSlotForPlayer("player1")
+	madlib.ClaimSlotForPlayer("player2") // nothing left, so player2 waits
+
+	madlib.ReleaseSlotsForPlayer("player1")
+	if prompt := madlib.GetPromptForPlayer("player2"); prompt != "noun" {
+		t.Fatalf("Expected the waiting player to get the freed slot, got %q", prompt)
+	}
+	if _, exists := madlib.playerPrompts["player1"]; exists {
+		t.Error("Expected the departed player to be forgotten")
+	}
+
+	// With nobody waiting the slot is free for the next to ask
+	madlib.ReleaseSlotsForPlayer("player2")
+	if madlib.claimedBy[0] != "" {
+		t.Fatalf("Expected the slot to be unclaimed, got %q", madlib.claimedBy[0])
+	}
+	madlib.ClaimSlotForPlayer("player3")
+	if madlib.AddWordForPlayer("player3", "hat"); !madlib.IsComplete() {
+		t.Error("Expected the story to complete once the slot was reclaimed")
+	}
+}
+
+func TestMadLibClaimTimeout(t *testing.T) {
+	madlib := oneSlotMadLib(2)
+	madlib.ClaimSlotForPlayer("player1")
+
+	// Alone, an idle player keeps their slot since nobody else could fill it
+	madlib.TickClaims()
+	madlib.TickClaims()
+	if madlib.claimedBy[0] != "player1" {
+		t.Fatalf("Expected player1 to keep the slot, got %q", madlib.claimedBy[0])
+	}
+
+	madlib.ClaimSlotForPlayer("player2")
+	if !madlib.TickClaims() {
+		t.Error("Expected a claim to be counting down")
+	}
+	madlib.TickClaims()
+	if madlib.claimedBy[0] != "player2" || madlib.playerPrompts["player1"] != -1 {
+		t.Fatalf("Expected the slot to pass to player2 and player1 to wait, got %q and %v", madlib.claimedBy[0], madlib.playerPrompts)
+	}
+	if left := madlib.claimLeft[0]; left != 2 {
+		t.Errorf("Expected player2 to get a fresh 2 seconds, got %d", left)
+	}
+
+	madlib.AddWordForPlayer("player2", "hat")
+	if madlib.TickClaims() {
+		t.Error("Expected no claims counting down once the story is filled in")
+	}
+}
+
+func TestMadLibActorPlayerLeaveReleasesSlot(t *testing.T) {
+	ga := NewGameActor("madlibs-leave")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "madlibs-leave", PlayerID: "player1", PlayerName: "Alice"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "madlibs-leave", PlayerID: "player2", PlayerName: "Bob"})
+	ga.mu.Lock()
+	ga.currentGame = "madlibs"
+	ga.state = "playing"
+	ga.game = oneSlotMadLib(0)
+	ga.mu.Unlock()
+
+	ga.Send(RequestPromptMsg{PlayerID: "player1"})
+	ga.Send(RequestPromptMsg{PlayerID: "player2"})
+	ga.Send(PlayerLeaveMsg{PlayerID: "player1"})
+	ga.Send(SubmitWordMsg{PlayerID: "player2", Word: "hat"})
+
+	if state := askState(t, ga); state.State != "finished" {
+		t.Errorf("Expected the story to finish after player1 left, got %q", state.State)
+	}
+}
+
+func TestMadLibActorClaimTimeout(t *testing.T) {
+	ga := NewGameActor("madlibs-timeout")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "madlibs-timeout", PlayerID: "player1", PlayerName: "Alice"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "madlibs-timeout", PlayerID: "player2", PlayerName: "Bob"})
+	ga.mu.Lock()
+	ga.currentGame = "madlibs"
+	ga.state = "playing"
+	ga.game = oneSlotMadLib(10)
+	ga.mu.Unlock()
+
+	ga.Send(RequestPromptMsg{PlayerID: "player1"})
+	ga.Send(RequestPromptMsg{PlayerID: "player2"})
+	for i := 0; i < 10; i++ {
+		ga.Send(TimerTickMsg{})
+	}
+	askState(t, ga)
+
+	ga.mu.RLock()
+	defer ga.mu.RUnlock()
+	if claimer := ga.game.(*MadLib).claimedBy[0]; claimer != "player2" {
+		t.Errorf("Expected player1's slot to time out and pass to player2, got %q", claimer)
+	}
+}
+
+func TestMadLibContributions(t *testing.T) {
+	madlib := NewMadLib(GameConfig{
+		AnonymousWords: true,
+		Content:        &Content{MadLibTemplates: []MadLibTemplate{{Template: "A {adjective} {noun}", Prompts: []string{"adjective", "noun"}}}},
+	})
+	madlib.ClaimSlotForPlayer("player1")
+	madlib.ClaimSlotForPlayer("player2")
+	madlib.AddWordForPlayer("player2", "hat")
+	madlib.AddWordForPlayer("player1", "soggy")
+
+	want := []Contribution{
+		{ID: "1", Prompt: "adjective", Word: "soggy", AuthorID: "player1"},
+		{ID: "2", Prompt: "noun", Word: "hat", AuthorID: "player2"},
+	}
+	if got := madlib.Contributions(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %+v, got %+v", want, got)
+	}
+
+	// Anonymous votes hide the authors, but players still know their own
+	view := madlib.PlayerView(PlayerView{State: "voting", Extra: map[string]interface{}{}}, "player2", RolePlayer)
+	contributions := view.Extra["contributions"].([]Contribution)
+	if contributions[0].AuthorID != "" || contributions[0].Mine || !contributions[1].Mine {
+		t.Errorf("Expected anonymous words with only player2's marked, got %+v", contributions)
+	}
+}
+
+func TestMadLibActorStoryVoting(t *testing.T) {
+	ga := NewGameActor("madlibs-vote")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "madlibs-vote", PlayerID: "player1", PlayerName: "Alice"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "madlibs-vote", PlayerID: "player2", PlayerName: "Bob"})
+	ga.mu.Lock()
+	ga.settings.StoryVoting = true
+	ga.currentGame = "madlibs"
+	ga.state = "playing"
+	ga.game = NewMadLib(GameConfig{
+		Content: &Content{MadLibTemplates: []MadLibTemplate{{Template: "A {adjective} {noun}", Prompts: []string{"adjective", "noun"}}}},
+	})
+	ga.mu.Unlock()
+
+	ga.Send(RequestPromptMsg{PlayerID: "player1"})
+	ga.Send(RequestPromptMsg{PlayerID: "player2"})
+	ga.Send(SubmitWordMsg{PlayerID: "player1", Word: "soggy"})
+	ga.Send(SubmitWordMsg{PlayerID: "player2", Word: "hat"})
+	if state := askState(t, ga); state.State != "voting" {
+		t.Fatalf("Expected a vote on the finished story, got %q", state.State)
+	}
+
+	ga.Send(VoteMsg{PlayerID: "player1", Contribution: "1"}) // their own word
+	ga.Send(VoteMsg{PlayerID: "player1", Contribution: "9"}) // no such word
+	askState(t, ga)
+	ga.mu.RLock()
+	if len(ga.votes) != 0 {
+		t.Errorf("Expected votes for your own or a missing word to be refused, got %v", ga.votes)
+	}
+	ga.mu.RUnlock()
+
+	ga.Send(VoteMsg{PlayerID: "player1", Contribution: "2"})
+	ga.Send(VoteMsg{PlayerID: "player2", Abstain: true})
+	state := askState(t, ga)
+	if state.State != "finished" {
+		t.Fatalf("Expected the vote to finish the game, got %q", state.State)
+	}
+
+	// Bob got a point for his word and the vote points for the funniest one
+	want := DefaultRoomSettings().SubmissionPoints + DefaultRoomSettings().VotePoints
+	if score := state.Players["player2"].Score; score != want {
+		t.Errorf("Expected Bob to score %d, got %d", want, score)
+	}
+	ga.mu.RLock()
+	defer ga.mu.RUnlock()
+	if result := ga.voteResults[0]; result.VotedFor != "Bob" || result.Word != "hat" {
+		t.Errorf("Expected Alice's vote to credit Bob's word, got %+v", result)
+	}
+}
+
+func TestMadLibFinishedViewLeavesStoryOut(t *testing.T) {
+	ga := NewGameActor("test-game")
+	ga.mu.Lock()
+	defer ga.mu.Unlock()
+	ga.currentGame = "madlibs"
+	ga.state = "finished"
+	ga.game = &MadLib{Template: "A {noun} story", Prompts: []string{"noun"}, Words: []string{"Mad Story"}}
+	ga.winners = []string{"Alice"}
+
+	// The story goes in the state on its own, so only the winners are listed
+	view, _ := ga.baseView()
+	if view.RoundInstructions != "Alice wins!" {
+		t.Errorf("Expected just the winners line, got %q", view.RoundInstructions)
+	}
+	if story := ga.buildRoomState().data.Story; story != "A Mad Story story" {
+		t.Errorf("Expected the story in the state, got %q", story)
+	}
+}
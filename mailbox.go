@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MailboxPolicy decides what Send does when an actor's inbox is full
+type MailboxPolicy int
+
+const (
+	// MailboxBlock waits for room in the inbox
+	MailboxBlock MailboxPolicy = iota
+	// MailboxDropNewest drops the message being sent
+	MailboxDropNewest
+	// MailboxDropOldest drops the oldest queued message to make room
+	MailboxDropOldest
+	// MailboxReject drops the message being sent and returns ErrMailboxFull
+	MailboxReject
+)
+
+func (p MailboxPolicy) String() string {
+	switch p {
+	case MailboxBlock:
+		return "block"
+	case MailboxDropNewest:
+		return "drop-newest"
+	case MailboxDropOldest:
+		return "drop-oldest"
+	case MailboxReject:
+		return "reject"
+	}
+	return fmt.Sprintf("MailboxPolicy(%d)", int(p))
+}
+
+// ErrMailboxFull is returned by Send under MailboxReject, and by an Ask
+// evicted under MailboxDropOldest
+var ErrMailboxFull = errors.New("mailbox full")
+
+// Reasons recorded on dead letters
+const (
+	ReasonMailboxFull  = "mailbox full"
+	ReasonEvicted      = "evicted by newer message"
+	ReasonActorStopped = "actor stopped"
+)
+
+// MailboxConfig configures an actor's inbox
+type MailboxConfig struct {
+	Name        string           // identifies the actor in dead letters and stats
+	Policy      MailboxPolicy    // what to do when the inbox is full
+	DeadLetters *DeadLetterQueue // where undeliverable messages go, nil to discard
+}
+
+// MailboxStats is a snapshot of an actor's mailbox counters
+type MailboxStats struct {
+	Name      string `json:"name"`
+	Policy    string `json:"policy"`
+	Queued    int    `json:"queued"`
+	Capacity  int    `json:"capacity"`
+	Delivered uint64 `json:"delivered"` // messages put in the inbox
+	Blocked   uint64 `json:"blocked"`   // sends that had to wait for room
+	Dropped   uint64 `json:"dropped"`   // messages dropped by the policy or because the actor stopped
+	Rejected  uint64 `json:"rejected"`  // sends refused with ErrMailboxFull
+}
+
+// mailboxCounters are updated atomically from any sender
+type mailboxCounters struct {
+	delivered atomic.Uint64
+	blocked   atomic.Uint64
+	dropped   atomic.Uint64
+	rejected  atomic.Uint64
+}
+
+// DeadLetter is a message that never reached its actor
+type DeadLetter struct {
+	Actor   string    `json:"actor"`
+	Message string    `json:"message"` // message type
+	Reason  string    `json:"reason"`
+	Time    time.Time `json:"time"`
+}
+
+// DeadLetterQueue keeps the most recent dead letters and a count per reason
+type DeadLetterQueue struct {
+	mu       sync.Mutex
+	capacity int
+	recent   []DeadLetter
+	total    uint64
+	byReason map[string]uint64
+}
+
+// DeadLetters is the sink used by game actors
+var DeadLetters = NewDeadLetterQueue(100)
+
+// NewDeadLetterQueue creates a queue remembering up to capacity letters
+func NewDeadLetterQueue(capacity int) *DeadLetterQueue {
+	return &DeadLetterQueue{
+		capacity: capacity,
+		byReason: make(map[string]uint64),
+	}
+}
+
+// Record adds a dead letter, forgetting the oldest one if the queue is full
+func (q *DeadLetterQueue) Record(actor string, msg ActorMessage, reason string) {
+	letter := DeadLetter{
+		Actor:   actor,
+		Message: fmt.Sprintf("%T", msg),
+		Reason:  reason,
+		Time:    time.Now(),
+	}
+	log.Printf("Dead letter for %s: %s (%s)", letter.Actor, letter.Message, reason)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.total++
+	q.byReason[reason]++
+	q.recent = append(q.recent, letter)
+	if len(q.recent) > q.capacity {
+		q.recent = q.recent[len(q.recent)-q.capacity:]
+	}
+}
+
+// Recent returns the remembered dead letters, oldest first
+func (q *DeadLetterQueue) Recent() []DeadLetter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]DeadLetter(nil), q.recent...)
+}
+
+// DeadLetterStats summarizes a dead letter queue for monitoring
+type DeadLetterStats struct {
+	Total    uint64            `json:"total"`
+	ByReason map[string]uint64 `json:"by_reason"`
+	Recent   []DeadLetter      `json:"recent"`
+}
+
+// Stats returns the counters and recent letters
+func (q *DeadLetterQueue) Stats() DeadLetterStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	byReason := make(map[string]uint64, len(q.byReason))
+	for k, v := range q.byReason {
+		byReason[k] = v
+	}
+	return DeadLetterStats{
+		Total:    q.total,
+		ByReason: byReason,
+		Recent:   append([]DeadLetter(nil), q.recent...),
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newFullActor returns an unstarted actor whose 2-slot inbox already holds
+// "first" and "second"
+func newFullActor(policy MailboxPolicy, deadLetters *DeadLetterQueue) *Actor {
+	actor := NewActor(func(msg ActorMessage) {}, 2)
+	actor.SetMailbox(MailboxConfig{Name: "test", Policy: policy, DeadLetters: deadLetters})
+	actor.Send(TestMsg{Value: "first"})
+	actor.Send(TestMsg{Value: "second"})
+	return actor
+}
+
+func queuedValues(actor *Actor) []string {
+	var values []string
+	for len(actor.inbox) > 0 {
+		values = append(values, (<-actor.inbox).(TestMsg).Value)
+	}
+	return values
+}
+
+func TestMailboxDropNewest(t *testing.T) {
+	deadLetters := NewDeadLetterQueue(10)
+	actor := newFullActor(MailboxDropNewest, deadLetters)
+
+	if err := actor.Send(TestMsg{Value: "third"}); err != nil {
+		t.Errorf("Expected drop-newest to drop silently, got %v", err)
+	}
+
+	if got := queuedValues(actor); len(got) != 2 || got[1] != "second" {
+		t.Errorf("Expected [first second] queued, got %v", got)
+	}
+	stats := actor.Stats()
+	if stats.Dropped != 1 || stats.Delivered != 2 {
+		t.Errorf("Expected 2 delivered and 1 dropped, got %+v", stats)
+	}
+	letters := deadLetters.Recent()
+	if len(letters) != 1 || letters[0].Actor != "test" || letters[0].Reason != ReasonMailboxFull {
+		t.Errorf("Expected a mailbox-full dead letter, got %+v", letters)
+	}
+}
+
+func TestMailboxDropOldest(t *testing.T) {
+	deadLetters := NewDeadLetterQueue(10)
+	actor := newFullActor(MailboxDropOldest, deadLetters)
+
+	if err := actor.Send(TestMsg{Value: "third"}); err != nil {
+		t.Errorf("Expected drop-oldest to accept the message, got %v", err)
+	}
+
+	if got := queuedValues(actor); len(got) != 2 || got[0] != "second" || got[1] != "third" {
+		t.Errorf("Expected [second third] queued, got %v", got)
+	}
+	if letters := deadLetters.Recent(); len(letters) != 1 || letters[0].Reason != ReasonEvicted {
+		t.Errorf("Expected the evicted message as a dead letter, got %+v", letters)
+	}
+}
+
+func TestMailboxDropOldestFailsEvictedAsk(t *testing.T) {
+	actor := NewActor(func(msg ActorMessage) {}, 1)
+	actor.SetMailbox(MailboxConfig{Name: "test", Policy: MailboxDropOldest})
+
+	errs := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := actor.Ask(ctx, TestMsg{Value: "question"})
+		errs <- err
+	}()
+	for len(actor.inbox) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	actor.Send(TestMsg{Value: "newer"})
+	select {
+	case err := <-errs:
+		if err != ErrMailboxFull {
+			t.Errorf("Expected the evicted Ask to fail with ErrMailboxFull, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the evicted Ask to be answered straight away")
+	}
+}
+
+func TestMailboxReject(t *testing.T) {
+	deadLetters := NewDeadLetterQueue(10)
+	actor := newFullActor(MailboxReject, deadLetters)
+
+	if err := actor.Send(TestMsg{Value: "third"}); err != ErrMailboxFull {
+		t.Errorf("Expected ErrMailboxFull, got %v", err)
+	}
+	if stats := actor.Stats(); stats.Rejected != 1 || stats.Queued != 2 || stats.Capacity != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if deadLetters.Stats().ByReason[ReasonMailboxFull] != 1 {
+		t.Error("Expected the rejected message to be recorded")
+	}
+}
+
+func TestMailboxBlockWaitsForRoom(t *testing.T) {
+	actor := newFullActor(MailboxBlock, nil)
+
+	sent := make(chan error, 1)
+	go func() { sent <- actor.Send(TestMsg{Value: "third"}) }()
+
+	select {
+	case <-sent:
+		t.Fatal("Expected Send to block while the inbox is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	actor.Start()
+	defer actor.Stop()
+
+	select {
+	case err := <-sent:
+		if err != nil {
+			t.Errorf("Expected blocked send to succeed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send still blocked after the actor started")
+	}
+	if actor.Stats().Blocked != 1 {
+		t.Errorf("Expected 1 blocked send, got %d", actor.Stats().Blocked)
+	}
+}
+
+func TestSendAfterStopIsDeadLetter(t *testing.T) {
+	deadLetters := NewDeadLetterQueue(10)
+	actor := NewActor(func(msg ActorMessage) {}, 10)
+	actor.SetMailbox(MailboxConfig{Name: "game room1", DeadLetters: deadLetters})
+	actor.Start()
+	actor.Stop()
+
+	if err := actor.Send(VoteMsg{PlayerID: "p1", VotedForID: "p2"}); err != ErrActorStopped {
+		t.Errorf("Expected ErrActorStopped, got %v", err)
+	}
+
+	letters := deadLetters.Recent()
+	if len(letters) != 1 || letters[0].Message != "main.VoteMsg" || letters[0].Reason != ReasonActorStopped {
+		t.Errorf("Expected the lost vote to be recorded, got %+v", letters)
+	}
+}
+
+func TestDeadLetterQueueKeepsMostRecent(t *testing.T) {
+	q := NewDeadLetterQueue(2)
+	for i := 0; i < 3; i++ {
+		q.Record("test", TestMsg{}, ReasonMailboxFull)
+	}
+
+	stats := q.Stats()
+	if stats.Total != 3 || len(stats.Recent) != 2 {
+		t.Errorf("Expected 3 total with 2 remembered, got %d and %d", stats.Total, len(stats.Recent))
+	}
+}
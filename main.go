@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -17,9 +24,39 @@ var upgrader = websocket.Upgrader{
 
 var coordinator *GameCoordinator
 
+// scoreStore keeps completed games; nil if it couldn't be opened
+var scoreStore *ScoreStore
+
 func main() {
 	coordinator = NewGameCoordinator()
 
+	// Scores live in an embedded file, set SCORES_DB to move it
+	scoresPath := os.Getenv("SCORES_DB")
+	if scoresPath == "" {
+		scoresPath = "scores.db"
+	}
+	store, err := OpenScoreStore(scoresPath)
+	if err != nil {
+		log.Printf("Score history disabled: %v", err)
+	} else {
+		scoreStore = store
+		coordinator.UseScoreStore(store)
+	}
+
+	// Content packs are read from CONTENT_DIR at startup, and reloaded
+	// when its files change. A bad pack stops the server from starting.
+	contentDir := os.Getenv("CONTENT_DIR")
+	if contentDir == "" {
+		contentDir = "content"
+	}
+	library, err := LoadContentLibrary(contentDir)
+	if err != nil {
+		log.Fatalf("Loading content packs: %v", err)
+	}
+	contentPacks = library
+	log.Printf("Loaded %d content packs", len(library.Packs()))
+	go library.Watch(2*time.Second, nil)
+
 	// Cleanup empty games periodically
 	go func() {
 		ticker := time.NewTicker(5 * time.Minute)
@@ -31,6 +68,14 @@ func main() {
 
 	http.HandleFunc("/ws", handleWebSocket)
 	http.HandleFunc("/api/user", handleUser)
+	http.HandleFunc("/api/protocol/schema", handleProtocolSchema)
+	http.HandleFunc("/api/metrics", handleMetrics)
+	http.HandleFunc("/api/leaderboard", handleLeaderboard)
+	http.HandleFunc("/api/content/packs", handleContentPacks)
+	http.HandleFunc("/api/madlibs/templates", handleMadLibTemplates)
+	http.HandleFunc("/api/madlibs/preview", handleMadLibPreview)
+	http.HandleFunc("/api/players/", handlePlayerHistory)
+	http.HandleFunc("/replay/", handleReplay)
 	http.Handle("/", http.FileServer(http.Dir("./static")))
 
 	log.Println("Server starting on :8080")
@@ -52,6 +97,210 @@ func handleUser(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// handleProtocolSchema serves the JSON Schema for the WebSocket protocol
+func handleProtocolSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	json.NewEncoder(w).Encode(ProtocolSchema())
+}
+
+// handleMetrics serves mailbox counters and dead letters for monitoring
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"mailboxes":    coordinator.MailboxStats(),
+		"dead_letters": DeadLetters.Stats(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleContentPacks lists the content packs rooms can choose from
+func handleContentPacks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(contentPacks.Packs())
+}
+
+// templateRequest is the body of the Mad Libs template endpoints. Prompts
+// can be left out; they're read from the template's {placeholders}.
+type templateRequest struct {
+	Template string   `json:"template"`
+	Prompts  []string `json:"prompts,omitempty"`
+	Words    []string `json:"words,omitempty"` // preview only: words to try, by position
+}
+
+// readTemplateRequest decodes a template endpoint's body, answering the
+// request itself if it can't
+func readTemplateRequest(w http.ResponseWriter, r *http.Request) (templateRequest, bool) {
+	var req templateRequest
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return req, false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return req, false
+	}
+	return req, true
+}
+
+// writeJSONError answers with {"error": message}, so API clients can show
+// why a template was refused
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// handleMadLibTemplates lists the Mad Libs templates rooms get by default
+// on GET. On POST an authenticated user adds a template to the custom
+// pack, and gets it back with its prompts filled in.
+func handleMadLibTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(contentPacks.Content(nil).MadLibTemplates)
+		return
+	}
+	req, ok := readTemplateRequest(w, r)
+	if !ok {
+		return
+	}
+	user := r.Header.Get("X-Remote-User")
+	if user == "" {
+		writeJSONError(w, http.StatusUnauthorized, "sign in to add templates for everyone")
+		return
+	}
+
+	template, err := contentPacks.AddTemplate(MadLibTemplate{Template: req.Template, Prompts: req.Prompts})
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Printf("%s added a Mad Libs template to the %s pack", user, CustomPack)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(template)
+}
+
+// handleMadLibPreview renders a template with the words given, or sample
+// words, without saving it
+func handleMadLibPreview(w http.ResponseWriter, r *http.Request) {
+	req, ok := readTemplateRequest(w, r)
+	if !ok {
+		return
+	}
+	preview, err := PreviewTemplate(MadLibTemplate{Template: req.Template, Prompts: req.Prompts}, req.Words)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+// handleLeaderboard serves authenticated players' totals, best first.
+// ?limit= caps the number of entries (default 20).
+func handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if scoreStore == nil {
+		http.Error(w, "score history is disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	entries, err := scoreStore.Leaderboard(queryLimit(r, 20))
+	if err != nil {
+		log.Printf("Leaderboard failed: %v", err)
+		http.Error(w, "leaderboard unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// handlePlayerHistory serves /api/players/{name}/history, a player's games
+// newest first. ?limit= caps the number of games (default 50).
+func handlePlayerHistory(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/players/"), "/history")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if scoreStore == nil {
+		http.Error(w, "score history is disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	games, err := scoreStore.History(name, queryLimit(r, 50))
+	if err != nil {
+		log.Printf("History for %s failed: %v", name, err)
+		http.Error(w, "history unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name":  name,
+		"games": games,
+	})
+}
+
+// replaySlots caps how many replays run at once. Each can take seconds of
+// CPU, and the endpoint needs no sign-in.
+var replaySlots = make(chan struct{}, 2)
+
+// handleReplay serves /replay/{id}: a closed room's event log and the
+// room's state after each event, replayed through a fresh GameActor. The
+// id is a log ID; /replay/ lists what's available.
+func handleReplay(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/replay/")
+	if id == "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(coordinator.Replays())
+		return
+	}
+
+	events := coordinator.EventLog(id)
+	if events == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	select {
+	case replaySlots <- struct{}{}:
+		defer func() { <-replaySlots }()
+	default:
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "too many replays running, try again shortly", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	steps, err := ReplayLog(ctx, events)
+
+	response := map[string]interface{}{
+		"log":   events,
+		"steps": steps,
+	}
+	if err != nil {
+		log.Printf("Replay of %s failed: %v", id, err)
+		response["error"] = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// queryLimit reads a positive ?limit= parameter, or returns def
+func queryLimit(r *http.Request, def int) int {
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 {
+		return limit
+	}
+	return def
+}
+
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -61,83 +310,340 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	var gameActor *GameActor
 	var playerID string
+	var spectating bool
+
+	// Set by nginx forward auth; authenticated users can take over hosting
+	remoteUser := r.Header.Get("X-Remote-User")
 
 	defer func() {
+		// Keep the player around for a while so they can resume
 		if gameActor != nil && playerID != "" {
-			gameActor.Send(PlayerLeaveMsg{PlayerID: playerID})
+			gameActor.Send(PlayerDisconnectMsg{PlayerID: playerID, Conn: conn})
 		}
 		conn.Close()
 	}()
 
+	// sendError reports a rejected message. Once joined, the actor owns all
+	// writes to the connection, so errors go through it.
+	sendError := func(perr *ProtocolError) {
+		log.Printf("Protocol error from player %s: %v", playerID, perr)
+		if gameActor != nil && playerID != "" {
+			gameActor.Send(NotifyPlayerMsg{PlayerID: playerID, Event: perr.Event()})
+			return
+		}
+		conn.WriteJSON(perr.Event())
+	}
+
 	for {
-		var msg map[string]interface{}
-		err := conn.ReadJSON(&msg)
+		_, raw, err := conn.ReadMessage()
 		if err != nil {
 			log.Println("Read error:", err)
 			break
 		}
 
-		action, ok := msg["action"].(string)
-		if !ok {
+		msg, perr := ParseClientMessage(raw)
+		if perr != nil {
+			sendError(perr)
 			continue
 		}
 
-		data, _ := msg["data"].(map[string]interface{})
+		// Everything except join, spectate and resume needs a player
+		if msg.Action != "join" && msg.Action != "spectate" && msg.Action != "resume" && gameActor == nil {
+			sendError(&ProtocolError{Code: ErrCodeNotJoined, Message: msg.Action + " sent before join"})
+			continue
+		}
+		if spectating && msg.Action != "ping" && msg.Action != "resync" {
+			sendError(&ProtocolError{Code: ErrCodeSpectating, Message: "Spectators can't " + msg.Action})
+			continue
+		}
 
-		switch action {
-		case "join":
-			gameID, _ := data["group"].(string)
-			playerName, _ := data["name"].(string)
-			playerID = generatePlayerID()
+		switch msg.Action {
+		case "join", "spectate":
+			// One player per socket: a second would get its own outbox
+			// writing to the same connection
+			if gameActor != nil {
+				sendError(&ProtocolError{Code: ErrCodeAlreadyJoined, Message: "Already in a game on this connection"})
+				continue
+			}
+			var req JoinRequest
+			if perr := msg.DecodePayload(&req); perr != nil {
+				sendError(perr)
+				continue
+			}
+			version, perr := NegotiateVersion(req.Version)
+			if perr != nil {
+				sendError(perr)
+				continue
+			}
 
+			gameID := req.Group
 			if gameID == "" {
 				gameID = "default"
 			}
 
-			gameActor = coordinator.GetOrCreateGame(gameID)
-			gameActor.Send(PlayerJoinMsg{
-				GameID:     gameID,
-				PlayerID:   playerID,
-				PlayerName: playerName,
-				Conn:       conn,
-			})
+			spectate := msg.Action == "spectate"
+			joinedActor, joinedID, perr := joinGame(gameID, req.Name, remoteUser, version, spectate, conn)
+			if perr != nil {
+				sendError(perr)
+				continue
+			}
+			if joinedActor == nil {
+				return // the join timed out and the connection was closed
+			}
+			gameActor = joinedActor
+			playerID = joinedID
+			spectating = spectate
 
-		case "next-game":
+		case "resume":
 			if gameActor != nil {
-				gameActor.Send(NextGameMsg{PlayerID: playerID})
+				continue
+			}
+			var req ResumeRequest
+			if perr := msg.DecodePayload(&req); perr != nil {
+				sendError(perr)
+				continue
+			}
+			version, perr := NegotiateVersion(req.Version)
+			if perr != nil {
+				sendError(perr)
+				continue
 			}
 
-		case "ping":
-			if gameActor != nil {
-				gameActor.Send(PingMsg{PlayerID: playerID})
+			resumedActor, resumedID := resumeSession(req.Group, req.Token, version, conn)
+			if resumedID == "" {
+				conn.WriteJSON(ResumeFailedEvent{Action: "resume-failed"})
+				continue
 			}
+			gameActor = resumedActor
+			playerID = resumedID
 
-		case "request-prompt":
-			if gameActor != nil {
-				gameActor.Send(RequestPromptMsg{PlayerID: playerID})
+		case "next-game":
+			var req NextGameRequest
+			if perr := msg.DecodePayload(&req); perr != nil {
+				sendError(perr)
+				continue
+			}
+			gameActor.Send(NextGameMsg{PlayerID: playerID, Game: req.Game})
+
+		case "set-selection":
+			var req SetSelectionRequest
+			if perr := msg.DecodePayload(&req); perr != nil {
+				sendError(perr)
+				continue
+			}
+			gameActor.Send(SetSelectionMsg{
+				PlayerID: playerID,
+				Mode:     SelectionMode(req.Mode),
+				Playlist: req.Playlist,
+			})
+
+		case "enable-game":
+			var req EnableGameRequest
+			if perr := msg.DecodePayload(&req); perr != nil {
+				sendError(perr)
+				continue
+			}
+			gameActor.Send(EnableGameMsg{PlayerID: playerID, Game: req.Game, Enabled: req.Enabled})
+
+		case "update-settings":
+			// Checked here for its types; the actor applies the fields it has
+			// to the room's current settings
+			var req RoomSettings
+			if perr := msg.DecodePayload(&req); perr != nil {
+				sendError(perr)
+				continue
+			}
+			gameActor.Send(UpdateSettingsMsg{PlayerID: playerID, Changes: msg.Data})
+
+		case "set-teams":
+			var req SetTeamsRequest
+			if perr := msg.DecodePayload(&req); perr != nil {
+				sendError(perr)
+				continue
+			}
+			gameActor.Send(SetTeamsMsg{PlayerID: playerID, Count: req.Count})
+
+		case "assign-team":
+			var req AssignTeamRequest
+			if perr := msg.DecodePayload(&req); perr != nil {
+				sendError(perr)
+				continue
+			}
+			gameActor.Send(AssignTeamMsg{PlayerID: playerID, TargetID: req.PlayerID, Team: req.Team})
+
+		case "balance-teams":
+			gameActor.Send(BalanceTeamsMsg{PlayerID: playerID})
+
+		case "rematch":
+			gameActor.Send(RematchMsg{PlayerID: playerID})
+
+		case "set-packs":
+			var req SetPacksRequest
+			if perr := msg.DecodePayload(&req); perr != nil {
+				sendError(perr)
+				continue
 			}
+			gameActor.Send(SetPacksMsg{PlayerID: playerID, Packs: req.Packs})
+
+		case "add-template":
+			var req AddTemplateRequest
+			if perr := msg.DecodePayload(&req); perr != nil {
+				sendError(perr)
+				continue
+			}
+			gameActor.Send(AddTemplateMsg{PlayerID: playerID, Template: MadLibTemplate{Template: req.Template, Prompts: req.Prompts}})
+
+		case "clear-templates":
+			gameActor.Send(ClearTemplatesMsg{PlayerID: playerID})
+
+		case "ping":
+			gameActor.Send(PingMsg{PlayerID: playerID})
+
+		case "request-prompt":
+			gameActor.Send(RequestPromptMsg{PlayerID: playerID})
+
+		case "resync":
+			gameActor.Send(ResyncMsg{PlayerID: playerID})
 
 		case "submit-word":
-			if gameActor != nil {
-				word, _ := data["word"].(string)
-				gameActor.Send(SubmitWordMsg{
-					PlayerID: playerID,
-					Word:     word,
-				})
+			var req SubmitWordRequest
+			if perr := msg.DecodePayload(&req); perr != nil {
+				sendError(perr)
+				continue
 			}
+			if err := gameActor.Send(SubmitWordMsg{
+				PlayerID: playerID,
+				Word:     req.Word,
+			}); err != nil {
+				log.Printf("Word from player %s was not delivered: %v", playerID, err)
+			}
+
+		case "kick", "ban":
+			var req KickRequest
+			if perr := msg.DecodePayload(&req); perr != nil {
+				sendError(perr)
+				continue
+			}
+			gameActor.Send(KickPlayerMsg{
+				PlayerID: playerID,
+				TargetID: req.PlayerID,
+				Ban:      msg.Action == "ban",
+			})
+
+		case "skip":
+			gameActor.Send(SkipGameMsg{PlayerID: playerID})
+
+		case "lock":
+			var req LockRequest
+			if perr := msg.DecodePayload(&req); perr != nil {
+				sendError(perr)
+				continue
+			}
+			gameActor.Send(LockRoomMsg{PlayerID: playerID, Locked: req.Locked})
 
 		case "vote":
-			if gameActor != nil {
-				votedForID, _ := data["player_id"].(string)
-				gameActor.Send(VoteMsg{
-					PlayerID:   playerID,
-					VotedForID: votedForID,
-				})
+			var req VoteRequest
+			if perr := msg.DecodePayload(&req); perr != nil {
+				sendError(perr)
+				continue
+			}
+			if err := gameActor.Send(VoteMsg{
+				PlayerID:     playerID,
+				VotedForID:   req.PlayerID,
+				Contribution: req.Contribution,
+				Abstain:      req.Abstain,
+			}); err != nil {
+				log.Printf("Vote from player %s was not delivered: %v", playerID, err)
 			}
 		}
 	}
 }
 
+// joinGame adds a new player or spectator to the given game. Returns a
+// protocol error if the room turned them away or is gone. If the room
+// didn't answer in time, it may still add the player and start writing to
+// conn, so the player is sent away, conn is closed and neither an actor nor
+// an error is returned.
+func joinGame(gameID, name, remoteUser string, version int, spectate bool, conn *websocket.Conn) (*GameActor, string, *ProtocolError) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	join := PlayerJoinMsg{
+		GameID:          gameID,
+		PlayerID:        generatePlayerID(),
+		PlayerName:      name,
+		Token:           generateSessionToken(),
+		RemoteUser:      remoteUser,
+		Spectate:        spectate,
+		ProtocolVersion: version,
+		Conn:            conn,
+	}
+	gameActor := coordinator.GetOrCreateGame(gameID)
+	reply, err := gameActor.Ask(ctx, join)
+	if err != nil && gameActor.Closing() {
+		// The room closed as we joined; the coordinator makes a new one
+		gameActor = coordinator.GetOrCreateGame(gameID)
+		reply, err = gameActor.Ask(ctx, join)
+	}
+
+	var perr *ProtocolError
+	if errors.As(err, &perr) {
+		return nil, "", perr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("Game %s didn't answer a join in time, closing the connection", gameID)
+		gameActor.Send(PlayerLeaveMsg{PlayerID: join.PlayerID})
+		conn.Close()
+		return nil, "", nil
+	}
+	if err != nil {
+		log.Printf("Error joining game %s: %v", gameID, err)
+		return nil, "", &ProtocolError{Code: ErrCodeRoomUnavailable, Message: "The room is not responding, try again"}
+	}
+	return gameActor, reply.(string), nil
+}
+
+// resumeSession rebinds conn to the player holding token in the given game.
+// Returns an empty player ID if the game or session no longer exists.
+func resumeSession(gameID, token string, version int, conn *websocket.Conn) (*GameActor, string) {
+	if gameID == "" {
+		gameID = "default"
+	}
+
+	gameActor := coordinator.GetGame(gameID)
+	if gameActor == nil || token == "" {
+		return nil, ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reply, err := gameActor.Ask(ctx, PlayerResumeMsg{
+		Token:           token,
+		ProtocolVersion: version,
+		Conn:            conn,
+	})
+	if err != nil {
+		log.Printf("Error resuming session in game %s: %v", gameID, err)
+		return nil, ""
+	}
+	playerID := reply.(string)
+	if playerID == "" {
+		return nil, ""
+	}
+	return gameActor, playerID
+}
+
 func generatePlayerID() string {
 	return time.Now().Format("20060102150405.000000")
 }
+
+// generateSessionToken returns a random token for resuming a session
+func generateSessionToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Error generating session token: %v", err)
+		return generatePlayerID()
+	}
+	return hex.EncodeToString(b)
+}
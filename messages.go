@@ -1,13 +1,23 @@
 package main
 
-import "github.com/gorilla/websocket"
+import (
+	"encoding/json"
 
-// Player actor messages
+	"github.com/gorilla/websocket"
+)
+
+// Player actor messages. Sent with Ask, the reply is the player's ID or a
+// *ProtocolError if the room turned them away.
 type PlayerJoinMsg struct {
 	GameID     string
 	PlayerID   string
 	PlayerName string
-	Conn       *websocket.Conn
+	Token      string // session token the player can later resume with
+	RemoteUser string // authenticated user from X-Remote-User, if any
+	Spectate   bool   // watch without playing
+	// Negotiated wire protocol version
+	ProtocolVersion int
+	Conn            *websocket.Conn `json:"-"`
 }
 
 func (m PlayerJoinMsg) ActorMessage() {}
@@ -18,12 +28,162 @@ type PlayerLeaveMsg struct {
 
 func (m PlayerLeaveMsg) ActorMessage() {}
 
+// PlayerDisconnectMsg reports a dropped connection. The player is kept as
+// disconnected until they resume or the grace period runs out.
+type PlayerDisconnectMsg struct {
+	PlayerID string
+	Conn     *websocket.Conn `json:"-"` // the connection that dropped
+}
+
+func (m PlayerDisconnectMsg) ActorMessage() {}
+
+// PlayerResumeMsg rebinds a new connection to the player owning Token.
+// Sent with Ask; the reply is the player's ID, or "" if the token is unknown.
+type PlayerResumeMsg struct {
+	Token           string
+	ProtocolVersion int
+	Conn            *websocket.Conn `json:"-"`
+}
+
+func (m PlayerResumeMsg) ActorMessage() {}
+
+// DisconnectTimeoutMsg fires when a disconnected player's grace period ends
+type DisconnectTimeoutMsg struct {
+	PlayerID    string
+	Disconnects int // the disconnect this timeout belongs to
+}
+
+func (m DisconnectTimeoutMsg) ActorMessage() {}
+
 type NextGameMsg struct {
 	PlayerID string
+	Game     string // the host's pick, if any
 }
 
 func (m NextGameMsg) ActorMessage() {}
 
+// KickPlayerMsg is the host removing a player, optionally banning them
+type KickPlayerMsg struct {
+	PlayerID string // the host
+	TargetID string
+	Ban      bool
+}
+
+func (m KickPlayerMsg) ActorMessage() {}
+
+// SkipGameMsg is the host skipping the current game
+type SkipGameMsg struct {
+	PlayerID string
+}
+
+func (m SkipGameMsg) ActorMessage() {}
+
+// LockRoomMsg is the host locking or unlocking the room to new joiners
+type LockRoomMsg struct {
+	PlayerID string
+	Locked   bool
+}
+
+func (m LockRoomMsg) ActorMessage() {}
+
+// SetSelectionMsg is the host changing how the next game is picked
+type SetSelectionMsg struct {
+	PlayerID string
+	Mode     SelectionMode
+	Playlist []string
+}
+
+func (m SetSelectionMsg) ActorMessage() {}
+
+// EnableGameMsg is the host turning a game on or off for the room
+type EnableGameMsg struct {
+	PlayerID string
+	Game     string
+	Enabled  bool
+}
+
+func (m EnableGameMsg) ActorMessage() {}
+
+// UpdateSettingsMsg is the host changing the room's rules. Changes is a
+// JSON object with any of RoomSettings' fields; the rest keep their values.
+type UpdateSettingsMsg struct {
+	PlayerID string
+	Changes  json.RawMessage
+}
+
+func (m UpdateSettingsMsg) ActorMessage() {}
+
+// SetTeamsMsg is the host turning team play on with Count auto-balanced
+// teams, or off with a Count of 0
+type SetTeamsMsg struct {
+	PlayerID string
+	Count    int
+}
+
+func (m SetTeamsMsg) ActorMessage() {}
+
+// AssignTeamMsg is the host moving a player to a team
+type AssignTeamMsg struct {
+	PlayerID string // the host
+	TargetID string
+	Team     int
+}
+
+func (m AssignTeamMsg) ActorMessage() {}
+
+// BalanceTeamsMsg is the host reshuffling players into even teams
+type BalanceTeamsMsg struct {
+	PlayerID string
+}
+
+func (m BalanceTeamsMsg) ActorMessage() {}
+
+// RematchMsg is the host starting a new match in the same room
+type RematchMsg struct {
+	PlayerID string
+}
+
+func (m RematchMsg) ActorMessage() {}
+
+// SetPacksMsg is the host choosing the content packs the room's games use.
+// No packs means every pack rated for everyone.
+type SetPacksMsg struct {
+	PlayerID string
+	Packs    []string
+}
+
+func (m SetPacksMsg) ActorMessage() {}
+
+// AddTemplateMsg is a player adding their own Mad Libs template to the room
+type AddTemplateMsg struct {
+	PlayerID string
+	Template MadLibTemplate
+}
+
+func (m AddTemplateMsg) ActorMessage() {}
+
+// ClearTemplatesMsg is the host removing the room's own templates
+type ClearTemplatesMsg struct {
+	PlayerID string
+}
+
+func (m ClearTemplatesMsg) ActorMessage() {}
+
+// ResyncMsg asks for a full state snapshot after a client missed a patch
+type ResyncMsg struct {
+	PlayerID string
+}
+
+func (m ResyncMsg) ActorMessage() {}
+
+// NotifyPlayerMsg delivers a protocol event to a single player
+type NotifyPlayerMsg struct {
+	PlayerID string
+	Event    interface{}
+}
+
+func (m NotifyPlayerMsg) ActorMessage() {}
+
 type PingMsg struct {
 	PlayerID string
 }
@@ -52,6 +212,8 @@ func (m RequestPromptMsg) ActorMessage() {}
 type VoteMsg struct {
 	PlayerID     string
 	VotedForID   string
+	Contribution string // in a story vote, the ID of the word voted for
+	Abstain      bool
 }
 
 func (m VoteMsg) ActorMessage() {}
@@ -60,15 +222,21 @@ type TimerTickMsg struct{}
 
 func (m TimerTickMsg) ActorMessage() {}
 
+// RoomResetMsg tells every player the room was reset after a failure
+type RoomResetMsg struct {
+	Reason string
+}
+
+func (m RoomResetMsg) ActorMessage() {}
+
 // Game state broadcast message
 type BroadcastStateMsg struct{}
 
 func (m BroadcastStateMsg) ActorMessage() {}
 
-// Get game state message (for queries)
-type GetGameStateMsg struct {
-	ResponseChan chan *GameState
-}
+// Get game state message (for queries). Sent with Ask; the reply is a
+// *GameState.
+type GetGameStateMsg struct{}
 
 func (m GetGameStateMsg) ActorMessage() {}
 
@@ -78,11 +246,19 @@ type GameState struct {
 	State       string // "lobby", "instructions", "playing"
 	CurrentGame string
 	Players     map[string]*PlayerInfo
+	HostID      string
+	Locked      bool
+	Seed        int64 // seed of the room's random choices
+	RoundSeed   int64 // seed of the current round's game
+	Spectators  int
+	Teams       []TeamData // nil unless in team play
 }
 
 type PlayerInfo struct {
-	ID    string
-	Name  string
-	Score int
-	Ready bool
+	ID           string
+	Name         string
+	Score        int
+	Ready        bool
+	Disconnected bool
+	Team         int
 }
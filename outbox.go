@@ -0,0 +1,175 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// OverflowPolicy decides what happens when a player's outbox is full
+type OverflowPolicy int
+
+const (
+	// OverflowCoalesce throws away queued state updates and queues a single
+	// fresh snapshot in their place. Other events are dropped.
+	OverflowCoalesce OverflowPolicy = iota
+	// OverflowDrop drops the new message. A dropped state update makes the
+	// next one a snapshot.
+	OverflowDrop
+	// OverflowDisconnect closes the connection; the player can resume
+	OverflowDisconnect
+)
+
+// OutboxConfig sizes a player's outbound queue
+type OutboxConfig struct {
+	Size         int           // frames that may be queued before the policy kicks in
+	WriteTimeout time.Duration // deadline for a single write
+	Policy       OverflowPolicy
+}
+
+// DefaultOutboxConfig is used by new game actors
+var DefaultOutboxConfig = OutboxConfig{
+	Size:         32,
+	WriteTimeout: 10 * time.Second,
+	Policy:       OverflowCoalesce,
+}
+
+// frameConn is the part of *websocket.Conn the write pump needs
+type frameConn interface {
+	WriteMessage(messageType int, data []byte) error
+	SetWriteDeadline(t time.Time) error
+	Close() error
+}
+
+type outFrame struct {
+	data  []byte
+	state bool // a state snapshot or patch, safe to coalesce
+}
+
+// Outbox is a player's bounded outbound queue, drained by its own goroutine
+// so a slow client never blocks the game actor
+type Outbox struct {
+	conn   frameConn
+	config OutboxConfig
+
+	mu       sync.Mutex
+	frames   []outFrame
+	closed   bool
+	draining bool // no new frames; close once the queue is empty
+
+	wake chan struct{}
+	done chan struct{}
+}
+
+// NewOutbox creates an outbox for conn and starts its write pump
+func NewOutbox(conn frameConn, config OutboxConfig) *Outbox {
+	if config.Size <= 0 {
+		config.Size = DefaultOutboxConfig.Size
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = DefaultOutboxConfig.WriteTimeout
+	}
+
+	o := &Outbox{
+		conn:   conn,
+		config: config,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go o.pump()
+	return o
+}
+
+// Push queues a frame. Returns false if the outbox is full or closed.
+func (o *Outbox) Push(data []byte, state bool) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed || o.draining || len(o.frames) >= o.config.Size {
+		return false
+	}
+	o.frames = append(o.frames, outFrame{data: data, state: state})
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// DropState removes every queued state update, leaving other events
+func (o *Outbox) DropState() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	kept := o.frames[:0]
+	for _, f := range o.frames {
+		if !f.state {
+			kept = append(kept, f)
+		}
+	}
+	o.frames = kept
+}
+
+// Close stops the write pump, which then closes the connection. Queued
+// frames are discarded. Safe to call more than once.
+func (o *Outbox) Close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.closed {
+		o.closed = true
+		o.frames = nil
+		close(o.done)
+	}
+}
+
+// CloseAfterFlush stops accepting frames and closes the connection once
+// everything already queued has been written
+func (o *Outbox) CloseAfterFlush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.draining = true
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pump writes queued frames until the outbox is closed or a write fails
+func (o *Outbox) pump() {
+	defer o.conn.Close()
+
+	for {
+		select {
+		case <-o.wake:
+		case <-o.done:
+			return
+		}
+
+		for {
+			o.mu.Lock()
+			if o.closed || len(o.frames) == 0 {
+				draining := o.draining
+				o.mu.Unlock()
+				if draining {
+					o.Close()
+					return
+				}
+				break
+			}
+			frame := o.frames[0]
+			o.frames = o.frames[1:]
+			o.mu.Unlock()
+
+			o.conn.SetWriteDeadline(time.Now().Add(o.config.WriteTimeout))
+			if err := o.conn.WriteMessage(websocket.TextMessage, frame.data); err != nil {
+				// Closing the socket ends the reader, which reports the disconnect
+				o.Close()
+				return
+			}
+		}
+	}
+}
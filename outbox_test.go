@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConn records writes. While blocked is non-nil every write waits on it,
+// like a client that stopped reading.
+type fakeConn struct {
+	mu      sync.Mutex
+	writes  [][]byte
+	blocked chan struct{}
+	fail    bool
+	closed  bool
+}
+
+func (c *fakeConn) WriteMessage(messageType int, data []byte) error {
+	c.mu.Lock()
+	blocked, fail := c.blocked, c.fail
+	c.mu.Unlock()
+
+	if blocked != nil {
+		<-blocked
+	}
+	if fail {
+		return errors.New("broken pipe")
+	}
+
+	c.mu.Lock()
+	c.writes = append(c.writes, data)
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *fakeConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func (c *fakeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *fakeConn) snapshot() ([][]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]byte(nil), c.writes...), c.closed
+}
+
+func TestOutboxDeliversInOrder(t *testing.T) {
+	conn := &fakeConn{}
+	out := NewOutbox(conn, OutboxConfig{Size: 4})
+	defer out.Close()
+
+	for _, msg := range []string{"a", "b", "c"} {
+		if !out.Push([]byte(msg), false) {
+			t.Fatalf("Push %q failed", msg)
+		}
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	writes, _ := conn.snapshot()
+	if len(writes) != 3 || string(writes[0]) != "a" || string(writes[2]) != "c" {
+		t.Errorf("Expected a, b, c in order, got %q", writes)
+	}
+}
+
+func TestOutboxBoundedWhenClientStalls(t *testing.T) {
+	conn := &fakeConn{blocked: make(chan struct{})}
+	out := NewOutbox(conn, OutboxConfig{Size: 2})
+	defer out.Close()
+
+	// The first frame is picked up by the pump and blocks there
+	out.Push([]byte("stuck"), true)
+	time.Sleep(20 * time.Millisecond)
+
+	if !out.Push([]byte("s1"), true) || !out.Push([]byte("pong"), false) {
+		t.Fatal("Expected room for two queued frames")
+	}
+	if out.Push([]byte("s2"), true) {
+		t.Error("Expected Push to fail once the outbox is full")
+	}
+
+	out.DropState()
+	if !out.Push([]byte("snapshot"), true) {
+		t.Error("Expected room after dropping queued state")
+	}
+
+	close(conn.blocked)
+	time.Sleep(50 * time.Millisecond)
+
+	writes, _ := conn.snapshot()
+	got := make([]string, len(writes))
+	for i, w := range writes {
+		got[i] = string(w)
+	}
+	want := []string{"stuck", "pong", "snapshot"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, got)
+			break
+		}
+	}
+}
+
+func TestOutboxWriteErrorClosesConn(t *testing.T) {
+	conn := &fakeConn{fail: true}
+	out := NewOutbox(conn, OutboxConfig{Size: 4})
+
+	out.Push([]byte("a"), false)
+	time.Sleep(50 * time.Millisecond)
+
+	if _, closed := conn.snapshot(); !closed {
+		t.Error("Expected the connection to be closed after a failed write")
+	}
+	if out.Push([]byte("b"), false) {
+		t.Error("Expected Push to fail after the pump stopped")
+	}
+}
+
+func TestGameActorSlowPlayerDoesNotBlockRoom(t *testing.T) {
+	ga := NewGameActor("test-game")
+	ga.outbox = OutboxConfig{Size: 2, Policy: OverflowCoalesce}
+	ga.Start()
+	defer ga.Stop()
+
+	// A player whose socket has stopped draining
+	stalled := &fakeConn{blocked: make(chan struct{})}
+	ga.mu.Lock()
+	ga.players["slow"] = &Player{ID: "slow", Name: "Slow", out: NewOutbox(stalled, ga.outbox)}
+	ga.mu.Unlock()
+
+	// Every join changes the scoreboard, so each one queues an update
+	for i := 0; i < 10; i++ {
+		ga.Send(PlayerJoinMsg{GameID: "test-game", PlayerID: fmt.Sprintf("player%d", i), PlayerName: "Alice"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	state, err := ga.GetState(ctx)
+	if err != nil {
+		t.Fatalf("Actor blocked on a stalled player: %v", err)
+	}
+	if len(state.Players) != 11 {
+		t.Errorf("Expected 11 players, got %d", len(state.Players))
+	}
+
+	// Once the client catches up, what it got adds up to the latest state
+	close(stalled.blocked)
+	time.Sleep(50 * time.Millisecond)
+
+	writes, _ := stalled.snapshot()
+	if len(writes) > 4 {
+		t.Errorf("Expected queued updates to be coalesced, got %d writes", len(writes))
+	}
+
+	var doc interface{}
+	for _, w := range writes {
+		var event struct {
+			Action string          `json:"action"`
+			State  json.RawMessage `json:"state"`
+			Ops    []PatchOp       `json:"ops"`
+		}
+		json.Unmarshal(w, &event)
+		switch event.Action {
+		case "state":
+			json.Unmarshal(event.State, &doc)
+		case "patch":
+			var err error
+			if doc, err = ApplyPatch(doc, event.Ops); err != nil {
+				t.Fatalf("Patch did not apply: %v", err)
+			}
+		}
+	}
+	final, _ := doc.(map[string]interface{})
+	if players, _ := final["players"].([]interface{}); len(players) != 11 {
+		t.Errorf("Expected the client to end up with 11 players, got %d", len(players))
+	}
+}
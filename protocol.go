@@ -0,0 +1,402 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Wire protocol versions. Clients send the version they speak on join or
+// resume; the server answers with the highest version both sides support.
+const (
+	ProtocolVersion    = 1
+	MinProtocolVersion = 1
+)
+
+// Error codes sent in ErrorEvent
+const (
+	ErrCodeBadMessage         = "bad_message"
+	ErrCodeUnknownAction      = "unknown_action"
+	ErrCodeBadPayload         = "bad_payload"
+	ErrCodeNotJoined          = "not_joined"
+	ErrCodeUnsupportedVersion = "unsupported_version"
+	ErrCodeNotHost            = "not_host"
+	ErrCodeUnknownPlayer      = "unknown_player"
+	ErrCodeRoomLocked         = "room_locked"
+	ErrCodeBanned             = "banned"
+	ErrCodeRoomUnavailable    = "room_unavailable"
+	ErrCodeBadSettings        = "bad_settings"
+	ErrCodeNoGame             = "no_game"
+	ErrCodeSpectating         = "spectating"
+	ErrCodeBadVote            = "bad_vote"
+	ErrCodeBadTemplate        = "bad_template"
+	ErrCodeAlreadyJoined      = "already_joined"
+)
+
+// ClientMessage is the envelope for every message a client sends
+type ClientMessage struct {
+	Action string          `json:"action"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// JoinRequest is the payload of the "join" and "spectate" actions
+type JoinRequest struct {
+	Group   string `json:"group"`
+	Name    string `json:"name"`
+	Version int    `json:"version,omitempty"` // protocol version the client speaks, 1 if omitted
+}
+
+// ResumeRequest is the payload of the "resume" action
+type ResumeRequest struct {
+	Group   string `json:"group"`
+	Token   string `json:"token"`
+	Version int    `json:"version,omitempty"`
+}
+
+// SubmitWordRequest is the payload of the "submit-word" action
+type SubmitWordRequest struct {
+	Word string `json:"word"`
+}
+
+// NextGameRequest is the payload of the "next-game" action. Game is the
+// host's pick and is required when the room's selection mode is host-picks.
+type NextGameRequest struct {
+	Game string `json:"game,omitempty"`
+}
+
+// SetSelectionRequest is the payload of the host-only "set-selection" action
+type SetSelectionRequest struct {
+	Mode     string   `json:"mode"` // random, playlist, shuffle or host-picks
+	Playlist []string `json:"playlist,omitempty"`
+}
+
+// EnableGameRequest is the payload of the host-only "enable-game" action
+type EnableGameRequest struct {
+	Game    string `json:"game"`
+	Enabled bool   `json:"enabled"`
+}
+
+// VoteRequest is the payload of the "vote" action. Abstaining counts as
+// voting without voting for anyone.
+type VoteRequest struct {
+	PlayerID     string `json:"player_id,omitempty"`
+	Contribution string `json:"contribution,omitempty"` // in a story vote, the ID of the word
+	Abstain      bool   `json:"abstain,omitempty"`
+}
+
+// ResyncRequest is the payload of the "resync" action, sent when a client
+// sees a patch whose base_seq doesn't match the last seq it applied
+type ResyncRequest struct {
+	Seq uint64 `json:"seq"` // last seq the client has
+}
+
+// KickRequest is the payload of the host-only "kick" and "ban" actions
+type KickRequest struct {
+	PlayerID string `json:"player_id"`
+}
+
+// LockRequest is the payload of the host-only "lock" action
+type LockRequest struct {
+	Locked bool `json:"locked"`
+}
+
+// SetTeamsRequest is the payload of the host-only "set-teams" action.
+// Count is the number of teams to auto-balance players into, or 0 to
+// turn team play off.
+type SetTeamsRequest struct {
+	Count int `json:"count"`
+}
+
+// AssignTeamRequest is the payload of the host-only "assign-team" action
+type AssignTeamRequest struct {
+	PlayerID string `json:"player_id"`
+	Team     int    `json:"team"`
+}
+
+// SetPacksRequest is the payload of the host-only "set-packs" action. An
+// empty list means every pack rated for everyone.
+type SetPacksRequest struct {
+	Packs []string `json:"packs"`
+}
+
+// AddTemplateRequest is the payload of the "add-template" action. Prompts
+// can be left out; they're read from the template's {placeholders}.
+type AddTemplateRequest struct {
+	Template string   `json:"template"`
+	Prompts  []string `json:"prompts,omitempty"`
+}
+
+// EmptyRequest is the payload of actions that carry no data
+type EmptyRequest struct{}
+
+// clientActions maps every inbound action to its payload type
+var clientActions = map[string]interface{}{
+	"join":            JoinRequest{},
+	"spectate":        JoinRequest{},
+	"resume":          ResumeRequest{},
+	"next-game":       NextGameRequest{},
+	"ping":            EmptyRequest{},
+	"request-prompt":  EmptyRequest{},
+	"submit-word":     SubmitWordRequest{},
+	"vote":            VoteRequest{},
+	"resync":          ResyncRequest{},
+	"kick":            KickRequest{},
+	"ban":             KickRequest{},
+	"skip":            EmptyRequest{},
+	"lock":            LockRequest{},
+	"set-selection":   SetSelectionRequest{},
+	"enable-game":     EnableGameRequest{},
+	"update-settings": RoomSettings{},
+	"set-teams":       SetTeamsRequest{},
+	"assign-team":     AssignTeamRequest{},
+	"balance-teams":   EmptyRequest{},
+	"rematch":         EmptyRequest{},
+	"set-packs":       SetPacksRequest{},
+	"add-template":    AddTemplateRequest{},
+	"clear-templates": EmptyRequest{},
+}
+
+// StateEvent carries a full snapshot of the room state as seen by one player
+type StateEvent struct {
+	Action string    `json:"action"`
+	Seq    uint64    `json:"seq"`
+	State  StateData `json:"state"`
+}
+
+// PatchEvent carries the changes to a player's state since BaseSeq. Clients
+// whose last seq isn't BaseSeq must send "resync" for a fresh snapshot.
+type PatchEvent struct {
+	Action  string    `json:"action"`
+	Seq     uint64    `json:"seq"`
+	BaseSeq uint64    `json:"base_seq"`
+	Ops     []PatchOp `json:"ops"`
+}
+
+// StateData is the room state sent to a player. Game-specific fields from
+// GameType.PlayerView are flattened into the same object.
+type StateData struct {
+	GameTitle         string           `json:"game_title"`
+	GameInstructions  string           `json:"game_instructions"`
+	RoundInstructions string           `json:"round_instructions"`
+	Players           []PlayerData     `json:"players"`
+	GameState         string           `json:"game_state"`
+	GameType          string           `json:"game_type"`
+	NeedsInput        bool             `json:"needs_input"`
+	HasTimer          bool             `json:"has_timer,omitempty"`
+	TimeRemaining     *int             `json:"time_remaining,omitempty"`
+	VotedPlayers      []string         `json:"voted_players,omitempty"`
+	TotalVotes        *int             `json:"total_votes,omitempty"`
+	ExpectedVotes     *int             `json:"expected_votes,omitempty"`
+	Runoff            []string         `json:"runoff,omitempty"`       // the tied players, during a runoff vote
+	VoteResults       []VoteResult     `json:"vote_results,omitempty"` // every vote cast, once the game finishes
+	Story             string           `json:"story,omitempty"`
+	HostID            string           `json:"host_id,omitempty"`
+	Locked            bool             `json:"locked,omitempty"`
+	Selection         *SelectionData   `json:"selection,omitempty"`       // only between games
+	Packs             []string         `json:"packs,omitempty"`           // content packs the room picked
+	AvailablePacks    []PackInfo       `json:"available_packs,omitempty"` // only between games
+	RoomTemplates     []MadLibTemplate `json:"room_templates,omitempty"`  // Mad Libs templates added to the room
+	Settings          RoomSettings     `json:"settings"`
+	Round             int              `json:"round,omitempty"` // round of the current game, see Settings.Rounds
+	Spectators        int              `json:"spectators,omitempty"`
+	Role              string           `json:"role,omitempty"`        // "spectator" for spectators, unset for players
+	RoundSeed         int64            `json:"round_seed,omitempty"`  // seed the last round's game was created with, once it's finished
+	Teams             []TeamData       `json:"teams,omitempty"`       // only in team play
+	ActingTeam        int              `json:"acting_team,omitempty"` // team whose turn it is in actor games
+	Match             []GameRecord     `json:"match,omitempty"`       // every finished round of the match so far
+	GamesPlayed       int              `json:"games_played,omitempty"`
+	Podium            []Standing       `json:"podium,omitempty"` // final standings, only on the podium
+
+	Extra map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON flattens Extra into the state object
+func (s StateData) MarshalJSON() ([]byte, error) {
+	type plain StateData
+	data, err := json.Marshal(plain(s))
+	if err != nil || len(s.Extra) == 0 {
+		return data, err
+	}
+
+	merged := make(map[string]interface{})
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for k, v := range s.Extra {
+		if _, exists := merged[k]; !exists {
+			merged[k] = v
+		}
+	}
+	return json.Marshal(merged)
+}
+
+// SelectionData is the room's game selection settings
+type SelectionData struct {
+	Mode     string       `json:"mode"`
+	Playlist []string     `json:"playlist"`
+	Disabled []string     `json:"disabled_games"`
+	Games    []GameOption `json:"games"`
+}
+
+// GameOption is a registered game the host can enable or pick
+type GameOption struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	MinPlayers int    `json:"min_players"`
+	MaxPlayers int    `json:"max_players,omitempty"`
+}
+
+// PlayerData is one entry of the scoreboard
+type PlayerData struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Score     int    `json:"score"`
+	Ready     bool   `json:"ready"`
+	Connected bool   `json:"connected"`
+	Team      int    `json:"team,omitempty"` // only in team play
+}
+
+// TeamData is one team's entry on the scoreboard
+type TeamData struct {
+	Team    int          `json:"team"`
+	Name    string       `json:"name"`
+	Score   int          `json:"score"`
+	Members []TeamMember `json:"members"`
+}
+
+// VoteResult is one vote cast in a game, by player name
+type VoteResult struct {
+	Voter    string `json:"voter"`
+	VotedFor string `json:"voted_for,omitempty"` // empty for an abstention
+	Word     string `json:"word,omitempty"`      // the word voted for, in a story vote
+	Runoff   bool   `json:"runoff,omitempty"`
+}
+
+// Standing is a player's or, in team play, a team's place in the match
+type Standing struct {
+	Place int    `json:"place"`
+	ID    string `json:"id,omitempty"` // player ID, empty for teams
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+}
+
+// TeamMember is a player's contribution to their team's score
+type TeamMember struct {
+	ID     string `json:"id"`
+	Points int    `json:"points"`
+}
+
+// SessionEvent tells a player who they are and how to resume
+type SessionEvent struct {
+	Action          string `json:"action"`
+	PlayerID        string `json:"player_id"`
+	Token           string `json:"token"`
+	ProtocolVersion int    `json:"protocol_version"`
+}
+
+// PongEvent answers a ping
+type PongEvent struct {
+	Action string `json:"action"`
+}
+
+// ResumeFailedEvent means the session is gone and the client should join again
+type ResumeFailedEvent struct {
+	Action string `json:"action"`
+}
+
+// KickedEvent tells a player the host removed them. The connection is
+// closed afterwards and the session can't be resumed.
+type KickedEvent struct {
+	Action string `json:"action"`
+	Banned bool   `json:"banned"`
+}
+
+// RoomResetEvent tells players the server recovered from an error by
+// resetting the room. Players and scores are kept.
+type RoomResetEvent struct {
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// ErrorEvent reports a rejected client message
+type ErrorEvent struct {
+	Action  string `json:"action"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// serverEvents maps every outbound action to its event type
+var serverEvents = map[string]interface{}{
+	"state":         StateEvent{},
+	"patch":         PatchEvent{},
+	"session":       SessionEvent{},
+	"pong":          PongEvent{},
+	"resume-failed": ResumeFailedEvent{},
+	"room-reset":    RoomResetEvent{},
+	"kicked":        KickedEvent{},
+	"error":         ErrorEvent{},
+}
+
+// NewErrorEvent builds an "error" event
+func NewErrorEvent(code, message string) ErrorEvent {
+	return ErrorEvent{Action: "error", Code: code, Message: message}
+}
+
+// ProtocolError is a client mistake that is reported back as an ErrorEvent
+type ProtocolError struct {
+	Code    string
+	Message string
+}
+
+func (e *ProtocolError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Event converts the error into the event sent to the client
+func (e *ProtocolError) Event() ErrorEvent {
+	return NewErrorEvent(e.Code, e.Message)
+}
+
+// ParseClientMessage decodes a raw WebSocket frame into an envelope
+func ParseClientMessage(raw []byte) (ClientMessage, *ProtocolError) {
+	var msg ClientMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return msg, &ProtocolError{Code: ErrCodeBadMessage, Message: "invalid JSON: " + err.Error()}
+	}
+	if msg.Action == "" {
+		return msg, &ProtocolError{Code: ErrCodeBadMessage, Message: "missing action"}
+	}
+	if _, known := clientActions[msg.Action]; !known {
+		return msg, &ProtocolError{Code: ErrCodeUnknownAction, Message: fmt.Sprintf("unknown action %q", msg.Action)}
+	}
+	return msg, nil
+}
+
+// DecodePayload decodes the envelope's data into the action's payload type
+func (m ClientMessage) DecodePayload(v interface{}) *ProtocolError {
+	if len(m.Data) == 0 || string(m.Data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(m.Data, v); err != nil {
+		return &ProtocolError{Code: ErrCodeBadPayload, Message: fmt.Sprintf("invalid %s payload: %v", m.Action, err)}
+	}
+	return nil
+}
+
+// NegotiateVersion picks the protocol version to speak with a client.
+// A zero client version means a client from before versioning (version 1).
+func NegotiateVersion(clientVersion int) (int, *ProtocolError) {
+	if clientVersion == 0 {
+		clientVersion = 1
+	}
+	version := clientVersion
+	if version > ProtocolVersion {
+		version = ProtocolVersion
+	}
+	if version < MinProtocolVersion {
+		return 0, &ProtocolError{
+			Code:    ErrCodeUnsupportedVersion,
+			Message: fmt.Sprintf("protocol version %d is not supported (need %d-%d)", clientVersion, MinProtocolVersion, ProtocolVersion),
+		}
+	}
+	return version, nil
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParseClientMessage(t *testing.T) {
+	msg, perr := ParseClientMessage([]byte(`{"action":"submit-word","data":{"word":"banana"}}`))
+	if perr != nil {
+		t.Fatalf("Unexpected error: %v", perr)
+	}
+
+	var req SubmitWordRequest
+	if perr := msg.DecodePayload(&req); perr != nil {
+		t.Fatalf("Unexpected payload error: %v", perr)
+	}
+	if req.Word != "banana" {
+		t.Errorf("Expected word 'banana', got '%s'", req.Word)
+	}
+}
+
+func TestParseClientMessageErrors(t *testing.T) {
+	tests := []struct {
+		raw  string
+		code string
+	}{
+		{`not json`, ErrCodeBadMessage},
+		{`{"data":{}}`, ErrCodeBadMessage},
+		{`{"action":"dance"}`, ErrCodeUnknownAction},
+	}
+
+	for _, tt := range tests {
+		_, perr := ParseClientMessage([]byte(tt.raw))
+		if perr == nil {
+			t.Errorf("Expected error for %s", tt.raw)
+			continue
+		}
+		if perr.Code != tt.code {
+			t.Errorf("Expected code %s for %s, got %s", tt.code, tt.raw, perr.Code)
+		}
+	}
+
+	msg, _ := ParseClientMessage([]byte(`{"action":"vote","data":{"player_id":42}}`))
+	var req VoteRequest
+	if perr := msg.DecodePayload(&req); perr == nil || perr.Code != ErrCodeBadPayload {
+		t.Errorf("Expected bad_payload for a numeric player_id, got %v", perr)
+	}
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	if v, perr := NegotiateVersion(0); perr != nil || v != 1 {
+		t.Errorf("Expected unversioned clients to get version 1, got %d (%v)", v, perr)
+	}
+	if v, perr := NegotiateVersion(ProtocolVersion + 5); perr != nil || v != ProtocolVersion {
+		t.Errorf("Expected newer clients to get version %d, got %d (%v)", ProtocolVersion, v, perr)
+	}
+	if _, perr := NegotiateVersion(-1); perr == nil || perr.Code != ErrCodeUnsupportedVersion {
+		t.Errorf("Expected unsupported_version, got %v", perr)
+	}
+}
+
+func TestStateDataFlattensExtra(t *testing.T) {
+	state := StateData{
+		GameTitle: "Title",
+		GameState: "playing",
+		Extra:     map[string]interface{}{"youtube_video_id": "abc", "game_title": "ignored"},
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	json.Unmarshal(data, &decoded)
+
+	if decoded["youtube_video_id"] != "abc" {
+		t.Errorf("Expected extra field to be flattened, got %v", decoded)
+	}
+	if decoded["game_title"] != "Title" {
+		t.Errorf("Extra fields must not override typed fields, got %v", decoded["game_title"])
+	}
+}
+
+func TestProtocolSchemaCoversActions(t *testing.T) {
+	data, err := json.Marshal(ProtocolSchema())
+	if err != nil {
+		t.Fatalf("Schema does not marshal: %v", err)
+	}
+	schema := string(data)
+
+	for action := range clientActions {
+		if !strings.Contains(schema, `"const":"`+action+`"`) {
+			t.Errorf("Schema missing client action %s", action)
+		}
+	}
+	for action := range serverEvents {
+		if !strings.Contains(schema, `"const":"`+action+`"`) {
+			t.Errorf("Schema missing server event %s", action)
+		}
+	}
+	if !strings.Contains(schema, `"JoinRequest"`) || !strings.Contains(schema, `"StateData"`) {
+		t.Error("Schema missing protocol struct definitions")
+	}
+
+	// update-settings takes any of the settings; state updates carry them all
+	defs := ProtocolSchema()["$defs"].(map[string]interface{})
+	if _, required := defs["RoomSettingsPatch"].(map[string]interface{})["required"]; required {
+		t.Error("Expected every field of an update-settings payload to be optional")
+	}
+	if _, required := defs["RoomSettings"].(map[string]interface{})["required"]; !required {
+		t.Error("Expected state updates to carry every setting")
+	}
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	coordinator = NewGameCoordinator()
+	defer coordinator.Stop()
+
+	server := httptest.NewServer(http.HandlerFunc(handleWebSocket))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	readEvent := func() map[string]interface{} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var event map[string]interface{}
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return event
+	}
+
+	// Actions before join are rejected
+	conn.WriteJSON(map[string]interface{}{"action": "next-game"})
+	if event := readEvent(); event["action"] != "error" || event["code"] != ErrCodeNotJoined {
+		t.Errorf("Expected not_joined error, got %v", event)
+	}
+
+	conn.WriteJSON(map[string]interface{}{
+		"action": "join",
+		"data":   map[string]interface{}{"group": "protocol-test", "name": "Alice", "version": ProtocolVersion},
+	})
+	session := readEvent()
+	if session["action"] != "session" || session["protocol_version"] != float64(ProtocolVersion) {
+		t.Errorf("Expected session event with protocol version, got %v", session)
+	}
+	if state := readEvent(); state["action"] != "state" {
+		t.Errorf("Expected state event after join, got %v", state)
+	}
+
+	// Unknown actions after join come back through the actor
+	conn.WriteJSON(map[string]interface{}{"action": "dance"})
+	if event := readEvent(); event["action"] != "error" || event["code"] != ErrCodeUnknownAction {
+		t.Errorf("Expected unknown_action error, got %v", event)
+	}
+
+	// A second join on the same socket is refused, so only one player
+	// writes to it
+	for _, action := range []string{"join", "spectate"} {
+		conn.WriteJSON(map[string]interface{}{
+			"action": action,
+			"data":   map[string]interface{}{"group": "protocol-test", "name": "Alice again"},
+		})
+		if event := readEvent(); event["action"] != "error" || event["code"] != ErrCodeAlreadyJoined {
+			t.Errorf("Expected already_joined error for a second %s, got %v", action, event)
+		}
+	}
+	state, err := coordinator.GetGame("protocol-test").GetState(context.Background())
+	if err != nil {
+		t.Fatalf("GetState failed: %v", err)
+	}
+	if len(state.Players) != 1 || state.Spectators != 0 {
+		t.Errorf("Expected just the one player, got %d players and %d spectators", len(state.Players), state.Spectators)
+	}
+}
+
+func TestWebSocketSendsPatchesAfterSnapshot(t *testing.T) {
+	coordinator = NewGameCoordinator()
+	defer coordinator.Stop()
+
+	server := httptest.NewServer(http.HandlerFunc(handleWebSocket))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	dial := func(name string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		conn.WriteJSON(map[string]interface{}{
+			"action": "join",
+			"data":   map[string]interface{}{"group": "delta-test", "name": name},
+		})
+		return conn
+	}
+	readEvent := func(conn *websocket.Conn) map[string]interface{} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var event map[string]interface{}
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return event
+	}
+
+	alice := dial("Alice")
+	defer alice.Close()
+	readEvent(alice) // session
+	snapshot := readEvent(alice)
+	if snapshot["action"] != "state" {
+		t.Fatalf("Expected first update to be a snapshot, got %v", snapshot)
+	}
+
+	bob := dial("Bob")
+	defer bob.Close()
+
+	patch := readEvent(alice)
+	if patch["action"] != "patch" {
+		t.Fatalf("Expected a patch when Bob joins, got %v", patch)
+	}
+	if patch["base_seq"] != snapshot["seq"] {
+		t.Errorf("Expected base_seq %v, got %v", snapshot["seq"], patch["base_seq"])
+	}
+
+	// A resync always gets a fresh snapshot
+	alice.WriteJSON(map[string]interface{}{"action": "resync", "data": map[string]interface{}{"seq": 0}})
+	if event := readEvent(alice); event["action"] != "state" || event["seq"] != patch["seq"] {
+		t.Errorf("Expected snapshot at seq %v after resync, got %v", patch["seq"], event)
+	}
+}
+
+// validateSchema checks value, decoded from JSON, against the parts of JSON
+// Schema that ProtocolSchema uses, returning the first mismatch
+func validateSchema(value interface{}, schema, defs map[string]interface{}, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		return validateSchema(value, defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{}), defs, path)
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an object, got %T", path, value)
+		}
+		if required, ok := schema["required"].([]string); ok {
+			for _, name := range required {
+				if _, exists := object[name]; !exists {
+					return fmt.Errorf("%s: missing %s", path, name)
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, field := range object {
+			fieldSchema, ok := properties[name].(map[string]interface{})
+			if !ok {
+				fieldSchema, _ = schema["additionalProperties"].(map[string]interface{})
+			}
+			if fieldSchema == nil {
+				continue
+			}
+			if err := validateSchema(field, fieldSchema, defs, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an array, got %T", path, value)
+		}
+		for i, item := range items {
+			if err := validateSchema(item, schema["items"].(map[string]interface{}), defs, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected a string, got %T", path, value)
+		}
+	case "integer", "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected a number, got %T", path, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean, got %T", path, value)
+		}
+	}
+	return nil
+}
+
+func TestStateWithMatchMatchesSchema(t *testing.T) {
+	ga := NewGameActor("test-game")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "p1", PlayerName: "Alice"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "test-game", PlayerID: "p2", PlayerName: "Bob"})
+	ga.Send(NextGameMsg{PlayerID: "p1", Game: "claudesgame"})
+	ga.Send(NextGameMsg{PlayerID: "p1"})
+	ga.Send(NextGameMsg{PlayerID: "p2"})
+	ga.Send(SubmitWordMsg{PlayerID: "p1", Word: "a"})
+	ga.Send(SubmitWordMsg{PlayerID: "p2", Word: "b"})
+	ga.Send(VoteMsg{PlayerID: "p1", VotedForID: "p2"})
+	ga.Send(VoteMsg{PlayerID: "p2", Abstain: true})
+	askState(t, ga)
+
+	ga.mu.Lock()
+	state := ga.buildRoomState().data
+	ga.mu.Unlock()
+	if len(state.Match) == 0 {
+		t.Fatal("Expected the finished game in the match summary")
+	}
+
+	data, err := json.Marshal(StateEvent{Action: "state", Seq: 1, State: state})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var event interface{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	defs := ProtocolSchema()["$defs"].(map[string]interface{})
+	if err := validateSchema(event, defs["StateEvent"].(map[string]interface{}), defs, "state"); err != nil {
+		t.Errorf("State doesn't match the published schema: %v", err)
+	}
+}
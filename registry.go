@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ScoringPolicy decides how points are awarded for submissions
+type ScoringPolicy int
+
+const (
+	// ScorePerSubmission awards the room's SubmissionPoints for every
+	// submission the game keeps. Games that can ignore one must implement
+	// AcceptingGame.
+	ScorePerSubmission ScoringPolicy = iota
+	// ScoreWinner awards the room's GuessPoints to the player whose
+	// submission completes the round. The game must implement WinnerGame.
+	ScoreWinner
+	// ScoreNone awards nothing for submissions (points only come from voting)
+	ScoreNone
+	// ScoreByGame leaves points to the game, which the actor collects after
+	// each submission and tick. The game must implement ScoredGame.
+	ScoreByGame
+)
+
+// GameDescriptor describes a game type to the registry
+type GameDescriptor struct {
+	ID           string
+	Name         string
+	MinPlayers   int
+	MaxPlayers   int           // 0 means no limit
+	NeedsActor   bool          // a random player acts each round (game must implement ActorGame)
+	NeedsVoting  bool          // completed rounds go to a vote
+	TimerSeconds int           // default round length, 0 if untimed; the server runs the timer for TimedGames
+	Scoring      ScoringPolicy // how submissions are scored
+	New          func(config GameConfig) GameType
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]GameDescriptor)
+	gameOrder  []string // registered IDs in registration order
+)
+
+// RegisterGame adds a game type to the registry. Games register themselves
+// from init(); registering the same ID twice is a programming error.
+func RegisterGame(desc GameDescriptor) {
+	if desc.ID == "" || desc.New == nil {
+		panic("RegisterGame: descriptor needs an ID and a constructor")
+	}
+	if desc.MinPlayers < 1 {
+		desc.MinPlayers = 1
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[desc.ID]; exists {
+		panic(fmt.Sprintf("RegisterGame: game %q registered twice", desc.ID))
+	}
+	registry[desc.ID] = desc
+	gameOrder = append(gameOrder, desc.ID)
+}
+
+// GameIDs lists registered game IDs in registration order
+func GameIDs() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]string(nil), gameOrder...)
+}
+
+// LookupGame returns the descriptor for a registered game type
+func LookupGame(gameType string) (GameDescriptor, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	desc, ok := registry[gameType]
+	return desc, ok
+}
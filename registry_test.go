@@ -0,0 +1,76 @@
+package main
+
+import "testing"
+
+func TestRegistryBuiltinGames(t *testing.T) {
+	ids := GameIDs()
+	if len(ids) != 8 {
+		t.Fatalf("Expected 8 registered games, got %d: %v", len(ids), ids)
+	}
+
+	for _, id := range ids {
+		desc, ok := LookupGame(id)
+		if !ok {
+			t.Errorf("Game %s in GameIDs but not in registry", id)
+			continue
+		}
+
+		game := CreateGame(id, DefaultRoomSettings().GameConfig(desc))
+		if game.GetID() != id {
+			t.Errorf("CreateGame(%s) returned game with ID %s", id, game.GetID())
+		}
+
+		if desc.NeedsActor {
+			if _, ok := game.(ActorGame); !ok {
+				t.Errorf("Game %s needs an actor but doesn't implement ActorGame", id)
+			}
+		}
+		if desc.TimerSeconds > 0 {
+			if _, ok := game.(TimedGame); !ok {
+				t.Errorf("Game %s is timed but doesn't implement TimedGame", id)
+			}
+		}
+		if desc.Scoring == ScoreWinner {
+			if _, ok := game.(WinnerGame); !ok {
+				t.Errorf("Game %s scores winners but doesn't implement WinnerGame", id)
+			}
+		}
+		if desc.Scoring == ScoreByGame {
+			if _, ok := game.(ScoredGame); !ok {
+				t.Errorf("Game %s scores itself but doesn't implement ScoredGame", id)
+			}
+		}
+	}
+}
+
+func TestRegistryPlayerLimits(t *testing.T) {
+	if GameFitsPlayers("charades", 1) || !GameFitsPlayers("charades", 2) {
+		t.Error("Expected charades to need 2 players")
+	}
+
+	// Solo players should never be given an actor game
+	for _, id := range GameIDs() {
+		if desc, _ := LookupGame(id); desc.NeedsActor && GameFitsPlayers(id, 1) {
+			t.Errorf("Actor game %s fits a single player", id)
+		}
+	}
+}
+
+func TestCreateGameUnknown(t *testing.T) {
+	if game := CreateGame("nope", DefaultRoomSettings().GameConfig(GameDescriptor{})); game != nil {
+		t.Errorf("Expected no game for an unknown ID, got %s", game.GetID())
+	}
+}
+
+func TestRegisterGameDuplicatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a duplicate game ID to panic")
+		}
+	}()
+
+	RegisterGame(GameDescriptor{
+		ID:  "madlibs",
+		New: func(GameConfig) GameType { return NewMadLib(GameConfig{}) },
+	})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProtocolSchema generates a JSON Schema document describing every client
+// action and server event, built from the protocol structs by reflection.
+func ProtocolSchema() map[string]interface{} {
+	defs := make(map[string]interface{})
+
+	clientVariants := []interface{}{}
+	for _, action := range sortedKeys(clientActions) {
+		payload := schemaRef(reflect.TypeOf(clientActions[action]), defs)
+		if patchActions[action] {
+			payload = patchSchemaRef(reflect.TypeOf(clientActions[action]), defs)
+		}
+		clientVariants = append(clientVariants, map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"action": map[string]interface{}{"const": action},
+				"data":   payload,
+			},
+			"required": []string{"action"},
+		})
+	}
+
+	serverVariants := []interface{}{}
+	for _, action := range sortedKeys(serverEvents) {
+		serverVariants = append(serverVariants, map[string]interface{}{
+			"allOf": []interface{}{
+				schemaRef(reflect.TypeOf(serverEvents[action]), defs),
+				map[string]interface{}{
+					"properties": map[string]interface{}{
+						"action": map[string]interface{}{"const": action},
+					},
+				},
+			},
+		})
+	}
+
+	defs["ClientMessage"] = map[string]interface{}{"oneOf": clientVariants}
+	defs["ServerEvent"] = map[string]interface{}{"oneOf": serverVariants}
+
+	return map[string]interface{}{
+		"$schema":          "https://json-schema.org/draft/2020-12/schema",
+		"$id":              "/api/protocol/schema",
+		"title":            "videogames2 WebSocket protocol",
+		"protocol_version": ProtocolVersion,
+		"min_version":      MinProtocolVersion,
+		"oneOf": []interface{}{
+			map[string]interface{}{"$ref": "#/$defs/ClientMessage"},
+			map[string]interface{}{"$ref": "#/$defs/ServerEvent"},
+		},
+		"$defs": defs,
+	}
+}
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+// patchActions take any of their payload's fields, leaving the rest as
+// they are
+var patchActions = map[string]bool{
+	"update-settings": true,
+}
+
+// patchSchemaRef returns the schema for struct t with every field optional,
+// registered in defs as t's name with "Patch" on the end
+func patchSchemaRef(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	name := t.Name() + "Patch"
+	if _, exists := defs[name]; !exists {
+		schema := structSchema(t, defs)
+		delete(schema, "required")
+		defs[name] = schema
+	}
+	return map[string]interface{}{"$ref": "#/$defs/" + name}
+}
+
+// schemaRef returns the schema for t, registering named structs in defs
+func schemaRef(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == rawMessageType || t.Kind() == reflect.Interface:
+		return map[string]interface{}{}
+	case t == timeType:
+		// Times marshal as RFC 3339 strings
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct:
+		if _, exists := defs[t.Name()]; !exists {
+			defs[t.Name()] = nil // reserve the name before recursing
+			defs[t.Name()] = structSchema(t, defs)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaRef(t.Elem(), defs)}
+	case t.Kind() == reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaRef(t.Elem(), defs)}
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = schemaRef(field.Type, defs)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// SelectionMode decides how a room picks its next game
+type SelectionMode string
+
+const (
+	// SelectRandom picks any enabled game that fits the room
+	SelectRandom SelectionMode = "random"
+	// SelectPlaylist plays the host's playlist in order, skipping games
+	// that don't fit the room, and wraps around at the end
+	SelectPlaylist SelectionMode = "playlist"
+	// SelectShuffle picks at random without repeats until every enabled
+	// game has been played
+	SelectShuffle SelectionMode = "shuffle"
+	// SelectHostPicks waits for the host to choose each game
+	SelectHostPicks SelectionMode = "host-picks"
+)
+
+// GameSelector holds a room's game selection settings and picks games
+type GameSelector struct {
+	Mode     SelectionMode
+	Playlist []string
+	Disabled map[string]bool
+
+	position int      // next playlist entry to try
+	bag      []string // games not yet played this shuffle cycle
+	rng      *rand.Rand
+}
+
+// NewGameSelector returns a selector picking any game at random using rng
+func NewGameSelector(rng *rand.Rand) *GameSelector {
+	return &GameSelector{
+		Mode:     SelectRandom,
+		Disabled: make(map[string]bool),
+		rng:      rng,
+	}
+}
+
+// SetMode changes the selection mode. A playlist is required for
+// SelectPlaylist and every entry must be a registered game.
+func (s *GameSelector) SetMode(mode SelectionMode, playlist []string) error {
+	switch mode {
+	case SelectRandom, SelectShuffle, SelectHostPicks:
+	case SelectPlaylist:
+		if len(playlist) == 0 {
+			return fmt.Errorf("playlist mode needs at least one game")
+		}
+		for _, game := range playlist {
+			if _, ok := LookupGame(game); !ok {
+				return fmt.Errorf("unknown game %q in playlist", game)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown selection mode %q", mode)
+	}
+
+	s.Mode = mode
+	s.Playlist = append([]string(nil), playlist...)
+	s.position = 0
+	s.bag = nil
+	return nil
+}
+
+// SetEnabled turns a game on or off for this room
+func (s *GameSelector) SetEnabled(game string, enabled bool) error {
+	if _, ok := LookupGame(game); !ok {
+		return fmt.Errorf("unknown game %q", game)
+	}
+	if enabled {
+		delete(s.Disabled, game)
+	} else {
+		s.Disabled[game] = true
+	}
+	return nil
+}
+
+// Allowed reports whether the room may play game with this many players
+func (s *GameSelector) Allowed(game string, playerCount int) bool {
+	return !s.Disabled[game] && GameFitsPlayers(game, playerCount)
+}
+
+// Next picks the next game. Returns "" if the host has to pick or if no
+// enabled game fits the room.
+func (s *GameSelector) Next(playerCount int) string {
+	switch s.Mode {
+	case SelectHostPicks:
+		return ""
+
+	case SelectPlaylist:
+		for i := range s.Playlist {
+			index := (s.position + i) % len(s.Playlist)
+			if game := s.Playlist[index]; s.Allowed(game, playerCount) {
+				s.position = index + 1
+				return game
+			}
+		}
+		return ""
+
+	case SelectShuffle:
+		candidates := s.fitting(s.bag, playerCount)
+		if len(candidates) == 0 {
+			// Everything left this cycle is played or doesn't fit; start over
+			s.bag = GameIDs()
+			candidates = s.fitting(s.bag, playerCount)
+		}
+		if len(candidates) == 0 {
+			return ""
+		}
+		game := candidates[s.rng.Intn(len(candidates))]
+		s.Played(game)
+		return game
+	}
+
+	candidates := s.fitting(GameIDs(), playerCount)
+	if len(candidates) == 0 {
+		return ""
+	}
+	return candidates[s.rng.Intn(len(candidates))]
+}
+
+// Played takes a game out of the current shuffle cycle. Next calls it
+// itself; call it when the host picks a game directly.
+func (s *GameSelector) Played(game string) {
+	for i, g := range s.bag {
+		if g == game {
+			s.bag = append(s.bag[:i], s.bag[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *GameSelector) fitting(games []string, playerCount int) []string {
+	fits := []string{}
+	for _, game := range games {
+		if s.Allowed(game, playerCount) {
+			fits = append(fits, game)
+		}
+	}
+	return fits
+}
+
+// Data describes the settings for the lobby UI
+func (s *GameSelector) Data() *SelectionData {
+	data := &SelectionData{
+		Mode:     string(s.Mode),
+		Playlist: append([]string{}, s.Playlist...),
+		Disabled: []string{},
+	}
+	for game := range s.Disabled {
+		data.Disabled = append(data.Disabled, game)
+	}
+	sort.Strings(data.Disabled)
+
+	for _, id := range GameIDs() {
+		desc, _ := LookupGame(id)
+		data.Games = append(data.Games, GameOption{
+			ID:         desc.ID,
+			Name:       desc.Name,
+			MinPlayers: desc.MinPlayers,
+			MaxPlayers: desc.MaxPlayers,
+		})
+	}
+	return data
+}
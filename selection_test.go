@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSelectorRandomSkipsDisabledGames(t *testing.T) {
+	s := NewGameSelector(rand.New(rand.NewSource(1)))
+	for _, game := range GameIDs() {
+		if game != "madlibs" {
+			s.SetEnabled(game, false)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		if game := s.Next(3); game != "madlibs" {
+			t.Fatalf("Expected only madlibs to be picked, got %s", game)
+		}
+	}
+
+	s.SetEnabled("madlibs", false)
+	if game := s.Next(3); game != "" {
+		t.Errorf("Expected no game with everything disabled, got %s", game)
+	}
+}
+
+func TestSelectorPlaylistSkipsGamesThatDontFit(t *testing.T) {
+	s := NewGameSelector(rand.New(rand.NewSource(1)))
+	if err := s.SetMode(SelectPlaylist, []string{"madlibs", "charades", "claudesgame"}); err != nil {
+		t.Fatalf("SetMode failed: %v", err)
+	}
+
+	// Charades needs 2 players, so a solo room skips it
+	want := []string{"madlibs", "claudesgame", "madlibs", "claudesgame"}
+	for i, w := range want {
+		if got := s.Next(1); got != w {
+			t.Errorf("Pick %d: expected %s, got %s", i, w, got)
+		}
+	}
+}
+
+func TestSelectorShuffleHasNoRepeatsPerCycle(t *testing.T) {
+	s := NewGameSelector(rand.New(rand.NewSource(1)))
+	s.SetMode(SelectShuffle, nil)
+
+	seen := make(map[string]bool)
+	for range GameIDs() {
+		game := s.Next(3)
+		if seen[game] {
+			t.Fatalf("Game %s repeated before the cycle finished", game)
+		}
+		seen[game] = true
+	}
+	if len(seen) != len(GameIDs()) {
+		t.Errorf("Expected every game once, got %v", seen)
+	}
+
+	// Next cycle starts over
+	if game := s.Next(3); game == "" {
+		t.Error("Expected a new cycle to start")
+	}
+}
+
+func TestSelectorHostPicksAndValidation(t *testing.T) {
+	s := NewGameSelector(rand.New(rand.NewSource(1)))
+	s.SetMode(SelectHostPicks, nil)
+	if game := s.Next(3); game != "" {
+		t.Errorf("Expected host-picks to leave the choice to the host, got %s", game)
+	}
+
+	if err := s.SetMode("chaos", nil); err == nil {
+		t.Error("Expected unknown mode to be rejected")
+	}
+	if err := s.SetMode(SelectPlaylist, nil); err == nil {
+		t.Error("Expected empty playlist to be rejected")
+	}
+	if err := s.SetMode(SelectPlaylist, []string{"nope"}); err == nil {
+		t.Error("Expected unknown game in playlist to be rejected")
+	}
+	if err := s.SetEnabled("nope", false); err == nil {
+		t.Error("Expected unknown game to be rejected")
+	}
+	if s.Mode != SelectHostPicks {
+		t.Errorf("Expected failed changes to leave the mode alone, got %s", s.Mode)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// RoomSettings are the house rules the host sets in the lobby
+type RoomSettings struct {
+	TimerSeconds     int      `json:"timer_seconds"`     // round length for timed games, 0 for each game's default
+	Rounds           int      `json:"rounds"`            // rounds of each game before picking the next
+	GuessPoints      int      `json:"guess_points"`      // for the winning guess in charades-style games
+	VotePoints       int      `json:"vote_points"`       // for winning a vote
+	SubmissionPoints int      `json:"submission_points"` // for each submission in games that score them
+	MatchGames       int      `json:"match_games"`       // games in a match, 0 for no limit
+	MatchPoints      int      `json:"match_points"`      // points that win the match, 0 for no target
+	AllowSelfVotes   bool     `json:"allow_self_votes"`  // players may vote for themselves
+	VoteSeconds      int      `json:"vote_seconds"`      // voting deadline, 0 to wait for every vote
+	TieBreak         TieBreak `json:"tie_break"`         // how tied votes are settled, empty for split
+	ClaimSeconds     int      `json:"claim_seconds"`     // how long a player can hold a Mad Libs slot, 0 for no limit
+	StoryVoting      bool     `json:"story_voting"`      // vote for the funniest word once a Mad Lib is done
+	AnonymousWords   bool     `json:"anonymous_words"`   // hide who wrote each word until the vote is over
+	TriviaQuestions  int      `json:"trivia_questions"`  // questions per Trivia game, 0 for the default
+}
+
+// Allowed ranges for RoomSettings
+const (
+	MinTimerSeconds = 10
+	MaxTimerSeconds = 300
+	MaxRounds       = 10
+	MaxPoints       = 10
+	MaxMatchGames   = 50
+	MaxMatchPoints  = 500
+)
+
+// DefaultRoomSettings returns the rules a new room starts with
+func DefaultRoomSettings() RoomSettings {
+	return RoomSettings{
+		TimerSeconds:     0,
+		Rounds:           1,
+		GuessPoints:      3,
+		VotePoints:       3,
+		SubmissionPoints: 1,
+		VoteSeconds:      60,
+		TieBreak:         TieSplit,
+		ClaimSeconds:     60,
+	}
+}
+
+// Validate checks every setting is in range
+func (s RoomSettings) Validate() error {
+	if s.TimerSeconds != 0 && (s.TimerSeconds < MinTimerSeconds || s.TimerSeconds > MaxTimerSeconds) {
+		return fmt.Errorf("timer must be between %d and %d seconds, or 0 for the game's default", MinTimerSeconds, MaxTimerSeconds)
+	}
+	if s.Rounds < 1 || s.Rounds > MaxRounds {
+		return fmt.Errorf("rounds must be between 1 and %d", MaxRounds)
+	}
+	if s.VoteSeconds != 0 && (s.VoteSeconds < MinTimerSeconds || s.VoteSeconds > MaxTimerSeconds) {
+		return fmt.Errorf("voting deadline must be between %d and %d seconds, or 0 for none", MinTimerSeconds, MaxTimerSeconds)
+	}
+	if s.ClaimSeconds != 0 && (s.ClaimSeconds < MinTimerSeconds || s.ClaimSeconds > MaxTimerSeconds) {
+		return fmt.Errorf("slot time limit must be between %d and %d seconds, or 0 for none", MinTimerSeconds, MaxTimerSeconds)
+	}
+	if s.TriviaQuestions < 0 || s.TriviaQuestions > MaxTriviaQuestions {
+		return fmt.Errorf("trivia questions must be between 1 and %d, or 0 for the default", MaxTriviaQuestions)
+	}
+	if err := s.TieBreak.valid(); err != nil {
+		return err
+	}
+	if s.MatchGames < 0 || s.MatchGames > MaxMatchGames {
+		return fmt.Errorf("match games must be between 0 and %d", MaxMatchGames)
+	}
+	if s.MatchPoints < 0 || s.MatchPoints > MaxMatchPoints {
+		return fmt.Errorf("match points must be between 0 and %d", MaxMatchPoints)
+	}
+	for name, points := range map[string]int{
+		"guess points":      s.GuessPoints,
+		"vote points":       s.VotePoints,
+		"submission points": s.SubmissionPoints,
+	} {
+		if points < 0 || points > MaxPoints {
+			return fmt.Errorf("%s must be between 0 and %d", name, MaxPoints)
+		}
+	}
+	return nil
+}
+
+// GameConfig is what a game's constructor gets from the room
+type GameConfig struct {
+	TimerSeconds    int        // round length, 0 if the game has no time limit
+	Rand            *rand.Rand // source of the game's random choices
+	Content         *Content   // word lists from the room's content packs
+	ClaimSeconds    int        // how long a player can hold a slot, 0 for no limit
+	AnonymousWords  bool       // hide who wrote each piece until the vote is over
+	TriviaQuestions int        // questions per game, 0 for the default
+}
+
+// random returns the config's RNG, or a time-seeded one if it has none
+func (c GameConfig) random() *rand.Rand {
+	if c.Rand != nil {
+		return c.Rand
+	}
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// content returns the config's word lists, or the built-in ones if it has
+// none
+func (c GameConfig) content() *Content {
+	if c.Content != nil {
+		return c.Content
+	}
+	return builtinContent
+}
+
+// GameConfig builds the config for a new game of the given type
+func (s RoomSettings) GameConfig(desc GameDescriptor) GameConfig {
+	config := GameConfig{
+		TimerSeconds:    desc.TimerSeconds,
+		ClaimSeconds:    s.ClaimSeconds,
+		AnonymousWords:  s.AnonymousWords,
+		TriviaQuestions: s.TriviaQuestions,
+	}
+	if s.TimerSeconds > 0 && desc.TimerSeconds > 0 {
+		config.TimerSeconds = s.TimerSeconds
+	}
+	return config
+}
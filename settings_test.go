@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestRoomSettingsValidate(t *testing.T) {
+	if err := DefaultRoomSettings().Validate(); err != nil {
+		t.Errorf("Expected defaults to be valid, got %v", err)
+	}
+
+	bad := []RoomSettings{
+		{TimerSeconds: 5, Rounds: 1},
+		{TimerSeconds: MaxTimerSeconds + 1, Rounds: 1},
+		{Rounds: 0},
+		{Rounds: MaxRounds + 1},
+		{Rounds: 1, VotePoints: -1},
+		{Rounds: 1, GuessPoints: MaxPoints + 1},
+		{Rounds: 1, MatchGames: -1},
+		{Rounds: 1, MatchPoints: MaxMatchPoints + 1},
+		{Rounds: 1, ClaimSeconds: 5},
+	}
+	for _, s := range bad {
+		if err := s.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", s)
+		}
+	}
+}
+
+func TestRoomSettingsGameConfig(t *testing.T) {
+	timed, _ := LookupGame("firsttofind")
+	untimed, _ := LookupGame("madlibs")
+
+	s := DefaultRoomSettings()
+	if got := s.GameConfig(timed).TimerSeconds; got != timed.TimerSeconds {
+		t.Errorf("Expected the game's default timer, got %d", got)
+	}
+
+	s.TimerSeconds = 45
+	if got := s.GameConfig(timed).TimerSeconds; got != 45 {
+		t.Errorf("Expected the room's timer, got %d", got)
+	}
+	if got := s.GameConfig(untimed).TimerSeconds; got != 0 {
+		t.Errorf("Expected untimed games to stay untimed, got %d", got)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// GameRecord is a completed game as kept in the score store
+type GameRecord struct {
+	ID         uint64         `json:"id"`
+	Room       string         `json:"room"`
+	Game       string         `json:"game"`
+	Round      int            `json:"round"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Winners    []string       `json:"winners"` // player names
+	Players    []PlayerResult `json:"players"`
+}
+
+// PlayerResult is one player's points in a completed game
+type PlayerResult struct {
+	Name   string `json:"name"`
+	User   string `json:"-"` // authenticated name, empty for guests; kept out of state and the API
+	Points int    `json:"points"`
+	Won    bool   `json:"won"`
+}
+
+// storedRecord is a GameRecord as kept in bbolt. Unlike the wire form it
+// keeps who was signed in, so a record stays tied to its players.
+type storedRecord struct {
+	GameRecord
+	Players []storedResult `json:"players"`
+}
+
+type storedResult struct {
+	PlayerResult
+	User string `json:"user,omitempty"`
+}
+
+func toStored(record GameRecord) storedRecord {
+	stored := storedRecord{GameRecord: record, Players: make([]storedResult, len(record.Players))}
+	for i, result := range record.Players {
+		stored.Players[i] = storedResult{PlayerResult: result, User: result.User}
+	}
+	return stored
+}
+
+func (s storedRecord) record() GameRecord {
+	record := s.GameRecord
+	record.Players = make([]PlayerResult, len(s.Players))
+	for i, result := range s.Players {
+		record.Players[i] = result.PlayerResult
+		record.Players[i].User = result.User
+	}
+	return record
+}
+
+// LeaderboardEntry is an authenticated player's totals across all games
+type LeaderboardEntry struct {
+	Name   string `json:"name"`
+	Games  int    `json:"games"`
+	Wins   int    `json:"wins"`
+	Points int    `json:"points"`
+}
+
+var (
+	gamesBucket   = []byte("games")   // record ID -> GameRecord
+	totalsBucket  = []byte("totals")  // user -> LeaderboardEntry
+	historyBucket = []byte("history") // user -> bucket of record IDs
+)
+
+// ScoreStore keeps game results in an embedded bbolt file, so scores
+// survive players leaving and rooms being cleaned up. Only authenticated
+// players get leaderboard totals and history; guests are kept in the game
+// records by display name.
+type ScoreStore struct {
+	db *bolt.DB
+}
+
+// OpenScoreStore opens or creates the store at path
+func OpenScoreStore(path string) (*ScoreStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening score store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{gamesBucket, totalsBucket, historyBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initializing score store %s: %w", path, err)
+	}
+	return &ScoreStore{db: db}, nil
+}
+
+// Close closes the store file
+func (s *ScoreStore) Close() error {
+	return s.db.Close()
+}
+
+// RecordGame saves a completed game and updates its players' totals. The
+// record's ID is assigned by the store.
+func (s *ScoreStore) RecordGame(record GameRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		games := tx.Bucket(gamesBucket)
+		id, err := games.NextSequence()
+		if err != nil {
+			return err
+		}
+		record.ID = id
+
+		data, err := json.Marshal(toStored(record))
+		if err != nil {
+			return err
+		}
+		if err := games.Put(recordKey(id), data); err != nil {
+			return err
+		}
+
+		for _, result := range record.Players {
+			if result.User == "" {
+				continue
+			}
+			if err := addToTotals(tx, result); err != nil {
+				return err
+			}
+			history, err := tx.Bucket(historyBucket).CreateBucketIfNotExists([]byte(result.User))
+			if err != nil {
+				return err
+			}
+			if err := history.Put(recordKey(id), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func addToTotals(tx *bolt.Tx, result PlayerResult) error {
+	totals := tx.Bucket(totalsBucket)
+	entry := LeaderboardEntry{Name: result.User}
+	if data := totals.Get([]byte(result.User)); data != nil {
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+	}
+
+	entry.Games++
+	entry.Points += result.Points
+	if result.Won {
+		entry.Wins++
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return totals.Put([]byte(result.User), data)
+}
+
+// Leaderboard returns up to limit players, most points first
+func (s *ScoreStore) Leaderboard(limit int) ([]LeaderboardEntry, error) {
+	entries := []LeaderboardEntry{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(totalsBucket).ForEach(func(k, v []byte) error {
+			var entry LeaderboardEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		return a.Name < b.Name
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// History returns up to limit of a player's games, newest first
+func (s *ScoreStore) History(user string, limit int) ([]GameRecord, error) {
+	records := []GameRecord{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		history := tx.Bucket(historyBucket).Bucket([]byte(user))
+		if history == nil {
+			return nil
+		}
+
+		games := tx.Bucket(gamesBucket)
+		c := history.Cursor()
+		for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+			if limit > 0 && len(records) >= limit {
+				break
+			}
+			var stored storedRecord
+			if err := json.Unmarshal(games.Get(k), &stored); err != nil {
+				return err
+			}
+			records = append(records, stored.record())
+		}
+		return nil
+	})
+	return records, err
+}
+
+// recordKey orders records by ID in bbolt's byte-sorted keys
+func recordKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openTestStore(t *testing.T) *ScoreStore {
+	store, err := OpenScoreStore(filepath.Join(t.TempDir(), "scores.db"))
+	if err != nil {
+		t.Fatalf("OpenScoreStore failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestScoreStoreLeaderboardAndHistory(t *testing.T) {
+	store := openTestStore(t)
+
+	games := []GameRecord{
+		{Room: "room1", Game: "madlibs", Winners: []string{"Alice"}, Players: []PlayerResult{
+			{Name: "Alice", User: "alice", Points: 3, Won: true},
+			{Name: "Bob", User: "bob", Points: 1},
+			{Name: "Guest", Points: 2},
+		}},
+		{Room: "room1", Game: "charades", Winners: []string{"Bob"}, Players: []PlayerResult{
+			{Name: "Alice", User: "alice", Points: 0},
+			{Name: "Bob", User: "bob", Points: 5, Won: true},
+		}},
+	}
+	for _, g := range games {
+		if err := store.RecordGame(g); err != nil {
+			t.Fatalf("RecordGame failed: %v", err)
+		}
+	}
+
+	board, err := store.Leaderboard(0)
+	if err != nil {
+		t.Fatalf("Leaderboard failed: %v", err)
+	}
+	want := []LeaderboardEntry{
+		{Name: "bob", Games: 2, Wins: 1, Points: 6},
+		{Name: "alice", Games: 2, Wins: 1, Points: 3},
+	}
+	if len(board) != len(want) {
+		t.Fatalf("Expected guests to be left off the leaderboard, got %+v", board)
+	}
+	for i := range want {
+		if board[i] != want[i] {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, want[i], board[i])
+		}
+	}
+
+	history, err := store.History("alice", 1)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Game != "charades" || history[0].ID != 2 {
+		t.Errorf("Expected only the newest game, got %+v", history)
+	}
+	if history, _ := store.History("nobody", 0); len(history) != 0 {
+		t.Errorf("Expected no history for an unknown player, got %+v", history)
+	}
+
+	// The store keeps who was signed in, but the wire form leaves it out
+	if len(history) == 0 {
+		return
+	}
+	if user := history[0].Players[0].User; user != "alice" {
+		t.Errorf("Expected the stored record to keep Alice's user, got %q", user)
+	}
+	data, err := json.Marshal(history[0])
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if strings.Contains(string(data), "alice") {
+		t.Errorf("Expected the signed-in name to stay off the wire, got %s", data)
+	}
+}
+
+func TestScoreStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scores.db")
+	store, err := OpenScoreStore(path)
+	if err != nil {
+		t.Fatalf("OpenScoreStore failed: %v", err)
+	}
+	store.RecordGame(GameRecord{Game: "madlibs", Players: []PlayerResult{{Name: "Alice", User: "alice", Points: 4}}})
+	store.Close()
+
+	store, err = OpenScoreStore(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer store.Close()
+
+	if board, _ := store.Leaderboard(10); len(board) != 1 || board[0].Points != 4 {
+		t.Errorf("Expected scores to survive a restart, got %+v", board)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// RestartStrategy decides what a supervised actor does after a handler panics
+type RestartStrategy int
+
+const (
+	// StrategyResume drops the failed message and carries on with the
+	// actor's state as it is
+	StrategyResume RestartStrategy = iota
+	// StrategyRestart calls the supervisor's Restart hook to put the actor
+	// back into a known good state, then carries on
+	StrategyRestart
+	// StrategyStop stops the actor
+	StrategyStop
+)
+
+func (s RestartStrategy) String() string {
+	switch s {
+	case StrategyResume:
+		return "resume"
+	case StrategyRestart:
+		return "restart"
+	case StrategyStop:
+		return "stop"
+	}
+	return fmt.Sprintf("RestartStrategy(%d)", int(s))
+}
+
+// Failure describes a recovered panic
+type Failure struct {
+	Message  ActorMessage    // message being handled when the handler panicked
+	Panic    interface{}     // value passed to panic
+	Stack    []byte          // stack trace of the panicking goroutine
+	Action   RestartStrategy // what the supervisor did about it
+	Restarts int             // failures recovered so far, including this one
+}
+
+// Supervisor recovers panics in an actor's handler and applies a strategy
+type Supervisor struct {
+	Strategy RestartStrategy
+
+	// More than MaxRestarts failures within Window escalates to StrategyStop.
+	// Zero MaxRestarts means no limit, and zero Window counts every failure.
+	MaxRestarts int
+	Window      time.Duration
+
+	// Restart puts the actor back into a known good state. Called on the
+	// actor's goroutine for StrategyRestart.
+	Restart func()
+
+	// OnFailure is told about every failure. Called on its own goroutine so
+	// it may send to the failed actor.
+	OnFailure func(Failure)
+
+	mu       sync.Mutex
+	restarts int
+	recent   []time.Time
+}
+
+// Restarts returns the number of failures recovered so far
+func (s *Supervisor) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
+
+// recovered handles a panic from the actor's handler. Returns false if the
+// actor should stop.
+func (s *Supervisor) recovered(msg ActorMessage, r interface{}, stack []byte) bool {
+	s.mu.Lock()
+	now := time.Now()
+	s.restarts++
+	failures := s.restarts
+	// Times are only needed to slide the window; without one, the count
+	// so far is enough
+	if s.Window > 0 {
+		s.recent = append(s.recent, now)
+		kept := s.recent[:0]
+		for _, t := range s.recent {
+			if now.Sub(t) <= s.Window {
+				kept = append(kept, t)
+			}
+		}
+		s.recent = kept
+		failures = len(s.recent)
+	}
+
+	action := s.Strategy
+	if s.MaxRestarts > 0 && failures > s.MaxRestarts {
+		action = StrategyStop
+	}
+	failure := Failure{Message: msg, Panic: r, Stack: stack, Action: action, Restarts: s.restarts}
+	s.mu.Unlock()
+
+	log.Printf("Actor panic handling %T: %v (%s)\n%s", msg, r, action, stack)
+
+	if action == StrategyRestart && s.Restart != nil && !s.restart() {
+		failure.Action = StrategyStop
+	}
+	if s.OnFailure != nil {
+		go s.OnFailure(failure)
+	}
+	return failure.Action != StrategyStop
+}
+
+// restart runs the Restart hook. Returns false if the hook panicked too.
+func (s *Supervisor) restart() (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Actor restart failed: %v", r)
+			ok = false
+		}
+	}()
+	s.Restart()
+	return true
+}
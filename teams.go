@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// teamNames name teams by number; team 1 is Red
+var teamNames = []string{"Red", "Blue", "Green", "Yellow"}
+
+// MaxTeams is the most teams a room can be split into
+var MaxTeams = len(teamNames)
+
+// Teams splits a room's players into numbered teams, 1 to Count. Team
+// points are kept here rather than summed from players, so a team keeps
+// what a member won after they leave or switch sides.
+type Teams struct {
+	Count   int
+	Members map[string]int // player ID -> team
+	Scores  map[int]int    // team -> points
+	Points  map[string]int // player ID -> points won for their current team
+
+	turn   int         // team that acts next, 0-based offset
+	rotate map[int]int // team -> how many times it has picked an actor
+}
+
+// NewTeams returns count empty teams. count must be 2 to MaxTeams.
+func NewTeams(count int) (*Teams, error) {
+	if count < 2 || count > MaxTeams {
+		return nil, fmt.Errorf("teams must number between 2 and %d", MaxTeams)
+	}
+	return &Teams{
+		Count:   count,
+		Members: make(map[string]int),
+		Scores:  make(map[int]int),
+		Points:  make(map[string]int),
+		rotate:  make(map[int]int),
+	}, nil
+}
+
+// Assign puts a player on a team. A player who switches sides starts
+// contributing afresh; what they won stays with their old team.
+func (t *Teams) Assign(playerID string, team int) error {
+	if team < 1 || team > t.Count {
+		return fmt.Errorf("no team %d", team)
+	}
+	if t.Members[playerID] != team {
+		delete(t.Points, playerID)
+	}
+	t.Members[playerID] = team
+	return nil
+}
+
+// Remove takes a player off their team
+func (t *Teams) Remove(playerID string) {
+	delete(t.Members, playerID)
+	delete(t.Points, playerID)
+}
+
+// TeamOf returns a player's team, or 0 if they have none
+func (t *Teams) TeamOf(playerID string) int {
+	return t.Members[playerID]
+}
+
+// Smallest returns the team with the fewest members, lowest number first
+func (t *Teams) Smallest() int {
+	sizes := make([]int, t.Count+1)
+	for _, team := range t.Members {
+		sizes[team]++
+	}
+	smallest := 1
+	for team := 2; team <= t.Count; team++ {
+		if sizes[team] < sizes[smallest] {
+			smallest = team
+		}
+	}
+	return smallest
+}
+
+// Balance deals the players out to the teams in a random order, so team
+// sizes differ by at most one. The new teams start from zero.
+func (t *Teams) Balance(playerIDs []string, rng *rand.Rand) {
+	ids := append([]string(nil), playerIDs...)
+	sort.Strings(ids) // map order isn't reproducible, the rng is
+	rng.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+
+	t.Members = make(map[string]int, len(ids))
+	t.Scores = make(map[int]int)
+	t.Points = make(map[string]int)
+	t.rotate = make(map[int]int)
+	t.turn = 0
+	for i, id := range ids {
+		t.Members[id] = i%t.Count + 1
+	}
+}
+
+// NextActor picks who acts next: teams take turns, and each team rotates
+// through its members. Only players in eligible can be picked, and only
+// from a team with someone eligible left to guess. Returns team 0 if no
+// team has two eligible members.
+func (t *Teams) NextActor(eligible []string) (int, string) {
+	members := make(map[int][]string)
+	for _, id := range eligible {
+		if team := t.Members[id]; team != 0 {
+			members[team] = append(members[team], id)
+		}
+	}
+
+	for i := 0; i < t.Count; i++ {
+		team := (t.turn+i)%t.Count + 1
+		ids := members[team]
+		if len(ids) < 2 {
+			continue
+		}
+		sort.Strings(ids)
+		actor := ids[t.rotate[team]%len(ids)]
+		t.rotate[team]++
+		t.turn = team % t.Count
+		return team, actor
+	}
+	return 0, ""
+}
+
+// AddPoints credits a player's team with points they won
+func (t *Teams) AddPoints(playerID string, points int) {
+	team, ok := t.Members[playerID]
+	if !ok {
+		return
+	}
+	t.Scores[team] += points
+	t.Points[playerID] += points
+}
+
+// ResetScores zeroes every team's points for a new match
+func (t *Teams) ResetScores() {
+	t.Scores = make(map[int]int)
+	t.Points = make(map[string]int)
+}
+
+// Data describes the teams for the scoreboard, with each member's share
+// of their team's points
+func (t *Teams) Data() []TeamData {
+	teams := make([]TeamData, t.Count)
+	for i := range teams {
+		teams[i] = TeamData{
+			Team:    i + 1,
+			Name:    teamNames[i],
+			Score:   t.Scores[i+1],
+			Members: []TeamMember{},
+		}
+	}
+	for id, team := range t.Members {
+		teams[team-1].Members = append(teams[team-1].Members, TeamMember{ID: id, Points: t.Points[id]})
+	}
+	for i := range teams {
+		members := teams[i].Members
+		sort.Slice(members, func(a, b int) bool { return members[a].ID < members[b].ID })
+	}
+	return teams
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestTeamsBalance(t *testing.T) {
+	teams, err := NewTeams(3)
+	if err != nil {
+		t.Fatalf("NewTeams failed: %v", err)
+	}
+	if _, err := NewTeams(1); err == nil {
+		t.Error("Expected a single team to be refused")
+	}
+	if _, err := NewTeams(MaxTeams + 1); err == nil {
+		t.Error("Expected too many teams to be refused")
+	}
+
+	teams.Scores[1] = 5
+	teams.Balance([]string{"a", "b", "c", "d", "e", "f", "g"}, rand.New(rand.NewSource(1)))
+
+	sizes := make(map[int]int)
+	for _, team := range teams.Members {
+		sizes[team]++
+	}
+	for team := 1; team <= 3; team++ {
+		if sizes[team] < 2 || sizes[team] > 3 {
+			t.Errorf("Expected team %d to have 2 or 3 players, got %d", team, sizes[team])
+		}
+	}
+	if teams.Scores[1] != 0 {
+		t.Errorf("Expected balancing to start the teams afresh, got %d points", teams.Scores[1])
+	}
+
+	// The same seed deals the same teams
+	again, _ := NewTeams(3)
+	again.Balance([]string{"g", "f", "e", "d", "c", "b", "a"}, rand.New(rand.NewSource(1)))
+	for id, team := range teams.Members {
+		if again.Members[id] != team {
+			t.Errorf("Expected %s on team %d with the same seed, got %d", id, team, again.Members[id])
+		}
+	}
+}
+
+func TestTeamsNextActorRotates(t *testing.T) {
+	teams, _ := NewTeams(2)
+	for id, team := range map[string]int{"a": 1, "b": 1, "c": 2, "d": 2, "e": 2} {
+		teams.Assign(id, team)
+	}
+	eligible := []string{"a", "b", "c", "d", "e"}
+
+	var got []string
+	for i := 0; i < 6; i++ {
+		_, actor := teams.NextActor(eligible)
+		got = append(got, actor)
+	}
+	want := []string{"a", "c", "b", "d", "a", "e"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected actors %v, got %v", want, got)
+		}
+	}
+
+	// A team with nobody left to guess is passed over
+	if team, actor := teams.NextActor([]string{"a", "c", "d"}); team != 2 || actor == "a" {
+		t.Errorf("Expected team 2 to act, got %s of team %d", actor, team)
+	}
+	if team, _ := teams.NextActor([]string{"a", "c"}); team != 0 {
+		t.Errorf("Expected no team to act without guessers, got team %d", team)
+	}
+}
+
+func TestTeamsPoints(t *testing.T) {
+	teams, _ := NewTeams(2)
+	teams.Assign("a", 1)
+	teams.Assign("b", 2)
+	teams.AddPoints("a", 3)
+	teams.AddPoints("b", 1)
+	teams.AddPoints("spectator", 5)
+
+	// Switching sides leaves the points with the old team
+	teams.Assign("a", 2)
+	teams.AddPoints("a", 2)
+
+	data := teams.Data()
+	if data[0].Name != "Red" || data[0].Score != 3 || len(data[0].Members) != 0 {
+		t.Errorf("Unexpected team 1: %+v", data[0])
+	}
+	if data[1].Score != 3 || len(data[1].Members) != 2 || data[1].Members[0] != (TeamMember{ID: "a", Points: 2}) {
+		t.Errorf("Unexpected team 2: %+v", data[1])
+	}
+}
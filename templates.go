@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MadLibTemplate is a story with a {placeholder} for each prompt, in order.
+// Prompts can be left out; Normalize reads them from the placeholders.
+type MadLibTemplate struct {
+	Template string   `json:"template" yaml:"template"`
+	Prompts  []string `json:"prompts,omitempty" yaml:"prompts,omitempty"`
+}
+
+// Limits on a template, so a submitted one fits on a phone screen and
+// doesn't take all night to fill in
+const (
+	MaxTemplateLength  = 2000
+	MaxTemplatePrompts = 30
+)
+
+// promptPattern is what a placeholder may be called: letters, digits,
+// spaces, underscores, dashes and apostrophes, in any language
+var promptPattern = regexp.MustCompile(`^[\p{L}\p{N}_ '-]+$`)
+
+// templatePart is a run of story text, or a placeholder to fill in
+type templatePart struct {
+	text   string
+	prompt bool
+}
+
+// parseTemplate splits a template into its text and placeholders. Every
+// { must be closed by a } before the next {, and a } needs an open {.
+func parseTemplate(template string) ([]templatePart, error) {
+	var parts []templatePart
+	start := 0
+	for i := 0; i < len(template); i++ {
+		switch template[i] {
+		case '{':
+			end := strings.IndexAny(template[i+1:], "{}")
+			if end < 0 || template[i+1+end] == '{' {
+				return nil, fmt.Errorf("the { at character %d is never closed", i+1)
+			}
+			name := strings.TrimSpace(template[i+1 : i+1+end])
+			if name == "" {
+				return nil, fmt.Errorf("the placeholder at character %d is empty", i+1)
+			}
+			if !promptPattern.MatchString(name) {
+				return nil, fmt.Errorf("placeholder {%s} can only use letters, digits, spaces, underscores, dashes and apostrophes", name)
+			}
+			if i > start {
+				parts = append(parts, templatePart{text: template[start:i]})
+			}
+			parts = append(parts, templatePart{text: name, prompt: true})
+			i += 1 + end
+			start = i + 1
+		case '}':
+			return nil, fmt.Errorf("the } at character %d has no {", i+1)
+		}
+	}
+	if start < len(template) {
+		parts = append(parts, templatePart{text: template[start:]})
+	}
+	return parts, nil
+}
+
+// ParseTemplate returns a template's prompts, one per {placeholder} in the
+// order they appear
+func ParseTemplate(template string) ([]string, error) {
+	if strings.TrimSpace(template) == "" {
+		return nil, errors.New("template is empty")
+	}
+	if len(template) > MaxTemplateLength {
+		return nil, fmt.Errorf("template is longer than %d characters", MaxTemplateLength)
+	}
+	parts, err := parseTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	var prompts []string
+	for _, part := range parts {
+		if part.prompt {
+			prompts = append(prompts, part.text)
+		}
+	}
+	if len(prompts) == 0 {
+		return nil, errors.New("template has no {placeholders}")
+	}
+	if len(prompts) > MaxTemplatePrompts {
+		return nil, fmt.Errorf("template has %d placeholders, the most is %d", len(prompts), MaxTemplatePrompts)
+	}
+	return prompts, nil
+}
+
+// Normalize checks the template parses and fills in its prompts from the
+// placeholders. Prompts that were given must match the placeholders.
+func (t *MadLibTemplate) Normalize() error {
+	prompts, err := ParseTemplate(t.Template)
+	if err != nil {
+		return err
+	}
+	if len(t.Prompts) == 0 {
+		t.Prompts = prompts
+		return nil
+	}
+	if len(prompts) != len(t.Prompts) {
+		return fmt.Errorf("template has %d placeholders but %d prompts", len(prompts), len(t.Prompts))
+	}
+	for i, prompt := range prompts {
+		if prompt != t.Prompts[i] {
+			return fmt.Errorf("placeholder %d is {%s} but its prompt is %q", i+1, prompt, t.Prompts[i])
+		}
+	}
+	return nil
+}
+
+// RenderTemplate fills each placeholder with the word at its position.
+// Placeholders without a word stay as they are, so a gap is visible rather
+// than silently dropped. A template that doesn't parse is returned as is.
+func RenderTemplate(template string, words []string) string {
+	parts, err := parseTemplate(template)
+	if err != nil {
+		return template
+	}
+
+	var story strings.Builder
+	slot := 0
+	for _, part := range parts {
+		if !part.prompt {
+			story.WriteString(part.text)
+			continue
+		}
+		if slot < len(words) && words[slot] != "" {
+			story.WriteString(words[slot])
+		} else {
+			story.WriteString("{" + part.text + "}")
+		}
+		slot++
+	}
+	return story.String()
+}
+
+// sampleWords fill in previews for the prompts the built-in templates use
+var sampleWords = map[string]string{
+	"adjective":       "sparkly",
+	"animal":          "platypus",
+	"exclamation":     "Great Scott",
+	"family_member":   "grandma",
+	"noun":            "teapot",
+	"number":          "42",
+	"occupation":      "astronaut",
+	"person_name":     "Pat",
+	"place":           "library",
+	"plural_noun":     "pickles",
+	"verb":            "juggle",
+	"verb_ing":        "skateboarding",
+	"verb_past_tense": "tiptoed",
+}
+
+// TemplatePreview is a template rendered with sample words
+type TemplatePreview struct {
+	Template string   `json:"template"`
+	Prompts  []string `json:"prompts"`
+	Words    []string `json:"words"`
+	Story    string   `json:"story"`
+}
+
+// PreviewTemplate renders a template with the given words, using a sample
+// word for any left out. Prompts with no sample word show in brackets.
+func PreviewTemplate(t MadLibTemplate, words []string) (TemplatePreview, error) {
+	if err := t.Normalize(); err != nil {
+		return TemplatePreview{}, err
+	}
+
+	filled := make([]string, len(t.Prompts))
+	for i, prompt := range t.Prompts {
+		switch {
+		case i < len(words) && strings.TrimSpace(words[i]) != "":
+			filled[i] = strings.TrimSpace(words[i])
+		case sampleWords[prompt] != "":
+			filled[i] = sampleWords[prompt]
+		default:
+			filled[i] = "[" + strings.ReplaceAll(prompt, "_", " ") + "]"
+		}
+	}
+	return TemplatePreview{
+		Template: t.Template,
+		Prompts:  t.Prompts,
+		Words:    filled,
+		Story:    RenderTemplate(t.Template, filled),
+	}, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	prompts, err := ParseTemplate("The {adjective} {noun} said '{ exclamation }' to the {adjective} {body part}.")
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	want := []string{"adjective", "noun", "exclamation", "adjective", "body part"}
+	if !reflect.DeepEqual(prompts, want) {
+		t.Errorf("Expected prompts %v, got %v", want, prompts)
+	}
+
+	bad := map[string]string{
+		"empty":           "   ",
+		"no placeholders": "Nothing to fill in here.",
+		"unclosed":        "A {noun and a {verb}",
+		"unopened":        "A noun} and a {verb}",
+		"blank":           "A {} and a {verb}",
+		"odd characters":  "A {noun!} and a {verb}",
+		"too long":        strings.Repeat("a", MaxTemplateLength) + "{noun}",
+		"too many":        strings.Repeat("{noun} ", MaxTemplatePrompts+1),
+	}
+	for what, template := range bad {
+		if _, err := ParseTemplate(template); err == nil {
+			t.Errorf("Expected a template that is %s to be refused", what)
+		}
+	}
+}
+
+func TestMadLibTemplateNormalize(t *testing.T) {
+	template := MadLibTemplate{Template: "A {noun} can {verb}"}
+	if err := template.Normalize(); err != nil {
+		t.Fatalf("Normalize failed: %v", err)
+	}
+	if !reflect.DeepEqual(template.Prompts, []string{"noun", "verb"}) {
+		t.Errorf("Expected prompts from the placeholders, got %v", template.Prompts)
+	}
+
+	mismatched := []MadLibTemplate{
+		{Template: "A {noun} can {verb}", Prompts: []string{"noun"}},
+		{Template: "A {noun} can {verb}", Prompts: []string{"verb", "noun"}},
+	}
+	for _, template := range mismatched {
+		if err := template.Normalize(); err == nil {
+			t.Errorf("Expected prompts %v to be refused", template.Prompts)
+		}
+	}
+}
+
+func TestRenderTemplateFillsByPosition(t *testing.T) {
+	// The same placeholder twice gets each word in turn
+	story := RenderTemplate("A {adjective} cat and a {adjective} {noun}", []string{"tiny", "huge", "hat"})
+	if story != "A tiny cat and a huge hat" {
+		t.Errorf("Unexpected story %q", story)
+	}
+
+	// Missing words leave their placeholder showing
+	story = RenderTemplate("A {adjective} {noun}", []string{"tiny"})
+	if story != "A tiny {noun}" {
+		t.Errorf("Expected the unfilled placeholder to stay, got %q", story)
+	}
+
+	m := &MadLib{Template: "A {noun} ate {noun}", Prompts: []string{"noun", "noun"}, Words: []string{"dog", "{noun}"}}
+	if story := m.GetStory(); story != "A dog ate {noun}" {
+		t.Errorf("Expected a word that looks like a placeholder to be left alone, got %q", story)
+	}
+}
+
+func TestPreviewTemplate(t *testing.T) {
+	preview, err := PreviewTemplate(MadLibTemplate{Template: "The {adjective} {noun} hurt my {body_part}"}, []string{"", "giraffe"})
+	if err != nil {
+		t.Fatalf("PreviewTemplate failed: %v", err)
+	}
+	if preview.Story != "The sparkly giraffe hurt my [body part]" {
+		t.Errorf("Unexpected preview %q", preview.Story)
+	}
+	if !reflect.DeepEqual(preview.Prompts, []string{"adjective", "noun", "body_part"}) {
+		t.Errorf("Unexpected prompts %v", preview.Prompts)
+	}
+
+	if _, err := PreviewTemplate(MadLibTemplate{Template: "A {noun"}, nil); err == nil {
+		t.Error("Expected a broken template to fail to preview")
+	}
+}
+
+func TestMadLibPreviewEndpoint(t *testing.T) {
+	body := `{"template": "My {noun} is {adjective}", "words": ["cat"]}`
+	rec := httptest.NewRecorder()
+	handleMadLibPreview(rec, httptest.NewRequest(http.MethodPost, "/api/madlibs/preview", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var preview TemplatePreview
+	if err := json.NewDecoder(rec.Body).Decode(&preview); err != nil {
+		t.Fatalf("Decoding preview: %v", err)
+	}
+	if preview.Story != "My cat is sparkly" {
+		t.Errorf("Unexpected preview %q", preview.Story)
+	}
+
+	rec = httptest.NewRecorder()
+	body = `{"template": "My {noun} is {adjective}", "prompts": ["noun"]}`
+	handleMadLibPreview(rec, httptest.NewRequest(http.MethodPost, "/api/madlibs/preview", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "2 placeholders but 1 prompts") {
+		t.Errorf("Expected the mismatch to be explained, got %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TriviaQuestion is a question from a question bank. With Choices it's
+// multiple choice and Answer must be one of them; without, players type
+// their answer and it's checked with triviaMatch.
+type TriviaQuestion struct {
+	Question string   `json:"question" yaml:"question"`
+	Choices  []string `json:"choices,omitempty" yaml:"choices,omitempty"`
+	Answer   string   `json:"answer" yaml:"answer"`
+}
+
+// Limits on trivia questions and games
+const (
+	MaxTriviaChoices = 6
+
+	DefaultTriviaQuestions = 5  // questions per game unless the room picks
+	MaxTriviaQuestions     = 20 // most questions the room can ask for
+
+	triviaRevealSeconds = 5  // how long each answer is shown
+	TriviaMaxPoints     = 10 // for a right answer the moment the question appears
+	TriviaMinPoints     = 5  // for a right answer as time runs out
+)
+
+var triviaQuestions = []TriviaQuestion{
+	{Question: "Which planet is known as the Red Planet?", Choices: []string{"Venus", "Mars", "Jupiter", "Saturn"}, Answer: "Mars"},
+	{Question: "How many legs does a spider have?", Choices: []string{"6", "8", "10", "12"}, Answer: "8"},
+	{Question: "What is the largest ocean on Earth?", Choices: []string{"Atlantic", "Indian", "Arctic", "Pacific"}, Answer: "Pacific"},
+	{Question: "In what year did people first walk on the Moon?", Choices: []string{"1959", "1969", "1979", "1989"}, Answer: "1969"},
+	{Question: "Which instrument has 88 keys?", Choices: []string{"Piano", "Guitar", "Violin", "Flute"}, Answer: "Piano"},
+	{Question: "What gas do plants take in from the air?", Choices: []string{"Oxygen", "Nitrogen", "Carbon dioxide", "Helium"}, Answer: "Carbon dioxide"},
+	{Question: "Which animal is called the King of the Jungle?", Choices: []string{"Tiger", "Lion", "Elephant", "Gorilla"}, Answer: "Lion"},
+	{Question: "What is the capital of France?", Answer: "Paris"},
+	{Question: "Who painted the Mona Lisa?", Answer: "Leonardo da Vinci"},
+	{Question: "What is the longest river in Africa?", Answer: "Nile"},
+	{Question: "What is the hardest natural substance?", Answer: "Diamond"},
+	{Question: "What colour do you get by mixing blue and yellow?", Answer: "Green"},
+	{Question: "Which country is home to the kangaroo?", Answer: "Australia"},
+	{Question: "What is the tallest mountain in the world?", Answer: "Mount Everest"},
+	{Question: "What is the chemical symbol for gold?", Choices: []string{"Ag", "Au", "Fe", "Pb"}, Answer: "Au"},
+}
+
+func init() {
+	RegisterGame(GameDescriptor{
+		ID:           "trivia",
+		Name:         "Trivia",
+		TimerSeconds: 20,
+		Scoring:      ScoreByGame,
+		New:          func(c GameConfig) GameType { return NewTrivia(c) },
+	})
+}
+
+// Validate checks a question can be asked and answered
+func (q TriviaQuestion) Validate() error {
+	if strings.TrimSpace(q.Question) == "" {
+		return errors.New("question is blank")
+	}
+	if strings.TrimSpace(q.Answer) == "" {
+		return fmt.Errorf("%q has no answer", q.Question)
+	}
+	if len(q.Choices) == 0 {
+		// triviaMatch only compares letters, so a number would match anything
+		if normalizeString(q.Answer) == "" {
+			return fmt.Errorf("%q: typed answers need letters; give choices for a number", q.Question)
+		}
+		return nil
+	}
+
+	if len(q.Choices) < 2 || len(q.Choices) > MaxTriviaChoices {
+		return fmt.Errorf("%q needs 2 to %d choices", q.Question, MaxTriviaChoices)
+	}
+	hasAnswer := false
+	for i, choice := range q.Choices {
+		if strings.TrimSpace(choice) == "" {
+			return fmt.Errorf("%q: choice %d is blank", q.Question, i+1)
+		}
+		for _, other := range q.Choices[:i] {
+			if strings.EqualFold(choice, other) {
+				return fmt.Errorf("%q lists %q twice", q.Question, choice)
+			}
+		}
+		hasAnswer = hasAnswer || choice == q.Answer
+	}
+	if !hasAnswer {
+		return fmt.Errorf("%q: the answer %q isn't one of the choices", q.Question, q.Answer)
+	}
+	return nil
+}
+
+// Trivia asks several questions in turn. Each runs on the server's clock
+// and is followed by a short reveal of the answer. Right answers score
+// more the faster they come in, and are paid out at the reveal.
+type Trivia struct {
+	questions       []TriviaQuestion
+	current         int
+	revealing       bool
+	questionSeconds int
+	timeRemaining   int
+	timerActive     bool
+	done            bool
+	numPlayers      int
+
+	answers map[string]string // this question's answers, one per player
+	points  map[string]int    // this question's points for each right answer
+	pending map[string]int    // points the actor hasn't collected yet
+}
+
+// TriviaReveal is what a player sees after a question closes
+type TriviaReveal struct {
+	Answer     string   `json:"answer"`
+	YourAnswer string   `json:"your_answer,omitempty"`
+	Correct    bool     `json:"correct"`
+	Points     int      `json:"points,omitempty"`
+	RightIDs   []string `json:"right_ids"` // players who got it right
+}
+
+func NewTrivia(config GameConfig) *Trivia {
+	bank := config.content().TriviaQuestions
+	count := config.TriviaQuestions
+	if count <= 0 {
+		count = DefaultTriviaQuestions
+	}
+	if count > len(bank) {
+		count = len(bank)
+	}
+
+	questions := make([]TriviaQuestion, count)
+	for i, idx := range config.random().Perm(len(bank))[:count] {
+		questions[i] = bank[idx]
+	}
+	return &Trivia{
+		questions:       questions,
+		questionSeconds: config.TimerSeconds,
+		timeRemaining:   config.TimerSeconds,
+		pending:         make(map[string]int),
+	}
+}
+
+// StartTimer asks the first question, giving each question seconds
+func (t *Trivia) StartTimer(seconds int) {
+	t.questionSeconds = seconds
+	t.timerActive = true
+	t.ask(0)
+}
+
+func (t *Trivia) ask(idx int) {
+	t.current = idx
+	t.revealing = false
+	t.timeRemaining = t.questionSeconds
+	t.answers = make(map[string]string)
+	t.points = make(map[string]int)
+}
+
+// reveal closes the question and pays out its points
+func (t *Trivia) reveal() {
+	t.revealing = true
+	t.timeRemaining = triviaRevealSeconds
+	for id, points := range t.points {
+		t.pending[id] += points
+	}
+}
+
+// isRight checks an answer: exactly for multiple choice, allowing typos
+// for typed answers
+func (t *Trivia) isRight(answer string) bool {
+	q := t.questions[t.current]
+	if len(q.Choices) > 0 {
+		return strings.EqualFold(strings.TrimSpace(answer), q.Answer)
+	}
+	return triviaMatch(answer, q.Answer)
+}
+
+// triviaMatch compares a whole typed answer with the right one, allowing a
+// typo or two in longer answers. Unlike fuzzyMatch it never accepts a guess
+// that merely contains the answer, so listing several answers doesn't work.
+func triviaMatch(guess, answer string) bool {
+	g, a := normalizeString(guess), normalizeString(answer)
+	if g == "" {
+		return false
+	}
+	typos := 0
+	switch {
+	case len(a) >= 8:
+		typos = 2
+	case len(a) >= 4:
+		typos = 1
+	}
+	return levenshteinDistance(g, a) <= typos
+}
+
+// speedPoints scales a right answer's points by how much time was left
+func (t *Trivia) speedPoints() int {
+	if t.questionSeconds <= 0 {
+		return TriviaMaxPoints
+	}
+	return TriviaMinPoints + (TriviaMaxPoints-TriviaMinPoints)*t.timeRemaining/t.questionSeconds
+}
+
+func (t *Trivia) GetName() string { return "Trivia" }
+func (t *Trivia) GetInstructions() string {
+	return fmt.Sprintf("Answer %d questions. The faster you get it right, the more points you score!", len(t.questions))
+}
+func (t *Trivia) GetID() string    { return "trivia" }
+func (t *Trivia) NeedsInput() bool { return t.timerActive && !t.revealing }
+func (t *Trivia) GetPrompt() string {
+	if len(t.questions) == 0 {
+		return ""
+	}
+	return t.questions[t.current].Question
+}
+
+// SubmitAnswer locks in a player's first answer to the open question. When
+// everyone has answered the reveal starts early. Only the clock ends the
+// game, so this never reports it complete.
+func (t *Trivia) SubmitAnswer(playerID, answer string) bool {
+	if !t.NeedsInput() || strings.TrimSpace(answer) == "" {
+		return false
+	}
+	if _, answered := t.answers[playerID]; answered {
+		return false
+	}
+
+	t.answers[playerID] = strings.TrimSpace(answer)
+	if t.isRight(answer) {
+		t.points[playerID] = t.speedPoints()
+	}
+	if t.numPlayers > 0 && len(t.answers) >= t.numPlayers {
+		t.reveal()
+	}
+	return false
+}
+func (t *Trivia) IsComplete() bool { return t.done }
+func (t *Trivia) GetResult() string {
+	return fmt.Sprintf("That's all %d questions!", len(t.questions))
+}
+func (t *Trivia) HasTimer() bool        { return true }
+func (t *Trivia) GetTimeRemaining() int { return t.timeRemaining }
+
+// DecrementTimer counts down the open question or reveal. A question that
+// runs out is revealed, a reveal moves on to the next question, and the
+// last reveal ends the game.
+func (t *Trivia) DecrementTimer() {
+	if !t.timerActive {
+		return
+	}
+	if t.timeRemaining > 0 {
+		t.timeRemaining--
+	}
+	if t.timeRemaining > 0 {
+		return
+	}
+
+	switch {
+	case !t.revealing:
+		t.reveal()
+	case t.current+1 < len(t.questions):
+		t.ask(t.current + 1)
+	default:
+		t.timerActive = false
+		t.done = true
+	}
+}
+
+func (t *Trivia) SetNumPlayers(n int) { t.numPlayers = n }
+
+// TakePoints hands over the points from revealed questions
+func (t *Trivia) TakePoints() map[string]int {
+	points := t.pending
+	t.pending = make(map[string]int)
+	return points
+}
+
+// PlayerView shows the open question, or the answer and how the player did
+func (t *Trivia) PlayerView(view PlayerView, playerID string, role PlayerRole) PlayerView {
+	if view.State != "playing" || !t.timerActive {
+		return view
+	}
+
+	q := t.questions[t.current]
+	view.RoundInstructions = fmt.Sprintf("Question %d of %d", t.current+1, len(t.questions))
+	view.Extra["question_number"] = t.current + 1
+	view.Extra["question_count"] = len(t.questions)
+
+	if t.revealing {
+		rightIDs := make([]string, 0, len(t.points))
+		for id := range t.points {
+			rightIDs = append(rightIDs, id)
+		}
+		sort.Strings(rightIDs)
+		view.Title = "The answer was: " + q.Answer
+		view.Instructions = ""
+		view.NeedsInput = false
+		view.Extra["reveal"] = TriviaReveal{
+			Answer:     q.Answer,
+			YourAnswer: t.answers[playerID],
+			Correct:    t.points[playerID] > 0,
+			Points:     t.points[playerID],
+			RightIDs:   rightIDs,
+		}
+		return view
+	}
+
+	_, answered := t.answers[playerID]
+	view.Title = q.Question
+	if len(q.Choices) > 0 {
+		view.Extra["choices"] = q.Choices
+		view.Instructions = "Pick an answer:"
+	} else {
+		view.Instructions = "Type your answer:"
+	}
+	if answered {
+		view.Instructions = "Answer locked in! Waiting for the others..."
+	}
+	view.Extra["answered"] = answered
+	view.Extra["answers_in"] = len(t.answers)
+	view.NeedsInput = !answered && role != RoleSpectator
+	return view
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// twoQuestionTrivia is a Trivia game with a multiple-choice question and
+// then a typed one, each open for 10 seconds
+func twoQuestionTrivia(t *testing.T) *Trivia {
+	t.Helper()
+	trivia := NewTrivia(GameConfig{
+		TriviaQuestions: 2,
+		Content: &Content{TriviaQuestions: []TriviaQuestion{
+			{Question: "Pick B", Choices: []string{"A", "B"}, Answer: "B"},
+			{Question: "Capital of France?", Answer: "Paris"},
+		}},
+	})
+	if trivia.questions[0].Question != "Pick B" {
+		trivia.questions[0], trivia.questions[1] = trivia.questions[1], trivia.questions[0]
+	}
+	trivia.SetNumPlayers(2)
+	trivia.StartTimer(10)
+	return trivia
+}
+
+func TestTriviaQuestionValidate(t *testing.T) {
+	for _, q := range triviaQuestions {
+		if err := q.Validate(); err != nil {
+			t.Errorf("Built-in question is invalid: %v", err)
+		}
+	}
+
+	bad := map[string]TriviaQuestion{
+		"no question":       {Answer: "Paris"},
+		"no answer":         {Question: "Capital of France?"},
+		"typed number":      {Question: "How many legs?", Answer: "8"},
+		"one choice":        {Question: "Pick", Choices: []string{"A"}, Answer: "A"},
+		"answer not listed": {Question: "Pick", Choices: []string{"A", "B"}, Answer: "C"},
+		"duplicate choice":  {Question: "Pick", Choices: []string{"A", "a"}, Answer: "A"},
+		"blank choice":      {Question: "Pick", Choices: []string{"A", " "}, Answer: "A"},
+	}
+	for what, q := range bad {
+		if err := q.Validate(); err == nil {
+			t.Errorf("Expected a question with %s to be refused", what)
+		}
+	}
+}
+
+func TestTriviaMatch(t *testing.T) {
+	right := map[string]string{
+		"Paris":             "paris",
+		"Nile":              "Nile!",
+		"Leonardo da Vinci": "leonardo da vinchi",
+		"Mount Everest":     "Mount Everst",
+	}
+	for answer, guess := range right {
+		if !triviaMatch(guess, answer) {
+			t.Errorf("Expected %q to count for %q", guess, answer)
+		}
+	}
+
+	wrong := map[string]string{
+		"Nile":      "amity nile paris shining",
+		"Paris":     "paris or rome",
+		"Australia": "",
+		"Green":     "grey",
+	}
+	for answer, guess := range wrong {
+		if triviaMatch(guess, answer) {
+			t.Errorf("Expected %q not to count for %q", guess, answer)
+		}
+	}
+}
+
+func TestTriviaQuestionsAndReveals(t *testing.T) {
+	trivia := twoQuestionTrivia(t)
+
+	// A right answer straight away is worth the most; only the first
+	// answer counts, and everyone answering reveals early
+	trivia.SubmitAnswer("p1", "b")
+	trivia.SubmitAnswer("p1", "A")
+	if trivia.revealing {
+		t.Fatal("Expected the question to stay open until everyone answered")
+	}
+	trivia.SubmitAnswer("p2", "A")
+	if !trivia.revealing || trivia.NeedsInput() {
+		t.Fatal("Expected the answer to be revealed once everyone answered")
+	}
+	if points := trivia.TakePoints(); !reflect.DeepEqual(points, map[string]int{"p1": TriviaMaxPoints}) {
+		t.Errorf("Expected only p1 to score full points, got %v", points)
+	}
+
+	view := trivia.PlayerView(PlayerView{State: "playing", Extra: map[string]interface{}{}}, "p2", RolePlayer)
+	reveal := view.Extra["reveal"].(TriviaReveal)
+	if reveal.Answer != "B" || reveal.YourAnswer != "A" || reveal.Correct || !reflect.DeepEqual(reveal.RightIDs, []string{"p1"}) {
+		t.Errorf("Unexpected reveal %+v", reveal)
+	}
+
+	for i := 0; i < triviaRevealSeconds; i++ {
+		trivia.DecrementTimer()
+	}
+	if trivia.GetPrompt() != "Capital of France?" || !trivia.NeedsInput() {
+		t.Fatalf("Expected the second question after the reveal, got %q", trivia.GetPrompt())
+	}
+
+	// Typed answers are checked loosely, and slower answers score less
+	for i := 0; i < 5; i++ {
+		trivia.DecrementTimer()
+	}
+	trivia.SubmitAnswer("p2", "pariss")
+	for i := 0; i < 5; i++ {
+		trivia.DecrementTimer()
+	}
+	if !trivia.revealing {
+		t.Fatal("Expected the question to be revealed when its time ran out")
+	}
+	want := TriviaMinPoints + (TriviaMaxPoints-TriviaMinPoints)/2
+	if points := trivia.TakePoints(); points["p2"] != want {
+		t.Errorf("Expected p2 to score %d with half the time left, got %v", want, points)
+	}
+
+	for i := 0; i < triviaRevealSeconds; i++ {
+		if trivia.IsComplete() {
+			t.Fatal("Expected the game to wait for the last reveal")
+		}
+		trivia.DecrementTimer()
+	}
+	if !trivia.IsComplete() || trivia.GetTimeRemaining() != 0 {
+		t.Error("Expected the game to end after the last reveal")
+	}
+}
+
+func TestGameActorTrivia(t *testing.T) {
+	ga := NewGameActor("trivia-game")
+	ga.Start()
+	defer ga.Stop()
+
+	askJoin(t, ga, PlayerJoinMsg{GameID: "trivia-game", PlayerID: "p1", PlayerName: "Alice"})
+	askJoin(t, ga, PlayerJoinMsg{GameID: "trivia-game", PlayerID: "p2", PlayerName: "Bob"})
+	ga.mu.Lock()
+	ga.currentGame = "trivia"
+	ga.state = "playing"
+	ga.game = twoQuestionTrivia(t)
+	ga.mu.Unlock()
+
+	// Scores only change at the reveal, so nobody learns the answer early
+	ga.Send(SubmitWordMsg{PlayerID: "p1", Word: "B"})
+	if state := askState(t, ga); state.Players["p1"].Score != 0 {
+		t.Errorf("Expected no points before the reveal, got %d", state.Players["p1"].Score)
+	}
+	ga.Send(SubmitWordMsg{PlayerID: "p2", Word: "A"})
+	if state := askState(t, ga); state.Players["p1"].Score != TriviaMaxPoints {
+		t.Errorf("Expected Alice to score %d at the reveal, got %d", TriviaMaxPoints, state.Players["p1"].Score)
+	}
+
+	// Answers during the reveal are ignored, and the clock runs the rest
+	ga.Send(SubmitWordMsg{PlayerID: "p2", Word: "B"})
+	for i := 0; i < triviaRevealSeconds+10+triviaRevealSeconds; i++ {
+		ga.Send(TimerTickMsg{})
+	}
+	state := askState(t, ga)
+	if state.State != "finished" {
+		t.Fatalf("Expected the game to finish on the clock, got %q", state.State)
+	}
+	if state.Players["p2"].Score != 0 {
+		t.Errorf("Expected Bob not to score, got %d", state.Players["p2"].Score)
+	}
+
+	ga.mu.RLock()
+	defer ga.mu.RUnlock()
+	if !reflect.DeepEqual(ga.winners, []string{"Alice"}) {
+		t.Errorf("Expected Alice to win on points, got %v", ga.winners)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TieBreak is how a vote that ends in a tie is settled
+type TieBreak string
+
+const (
+	// TieSplit shares the vote points between the tied players, rounded down
+	TieSplit TieBreak = "split"
+	// TieRunoff holds a second vote between the tied players; a tied
+	// runoff is split
+	TieRunoff TieBreak = "runoff"
+	// TieRandom gives the win to one of the tied players at random
+	TieRandom TieBreak = "random"
+)
+
+func (t TieBreak) valid() error {
+	switch t {
+	case "", TieSplit, TieRunoff, TieRandom:
+		return nil
+	}
+	return fmt.Errorf("unknown tie break %q", t)
+}
+
+// topVoted returns the players with the most votes, sorted by ID. Empty
+// votes are abstentions and count for nobody. Nobody is top voted if
+// everyone abstained.
+func topVoted(votes map[string]string) []string {
+	counts := make(map[string]int)
+	most := 0
+	for _, votedFor := range votes {
+		if votedFor == "" {
+			continue
+		}
+		counts[votedFor]++
+		if counts[votedFor] > most {
+			most = counts[votedFor]
+		}
+	}
+
+	var top []string
+	for id, count := range counts {
+		if count == most {
+			top = append(top, id)
+		}
+	}
+	sort.Strings(top)
+	return top
+}
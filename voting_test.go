@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTopVoted(t *testing.T) {
+	cases := []struct {
+		votes map[string]string
+		want  []string
+	}{
+		{map[string]string{"a": "b", "b": "c", "c": "b"}, []string{"b"}},
+		{map[string]string{"a": "b", "b": "a", "c": ""}, []string{"a", "b"}},
+		{map[string]string{"a": "", "b": ""}, nil},
+		{map[string]string{}, nil},
+	}
+	for _, c := range cases {
+		if got := topVoted(c.votes); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("topVoted(%v) = %v, want %v", c.votes, got, c.want)
+		}
+	}
+}
+
+func TestTieBreakValid(t *testing.T) {
+	for _, tb := range []TieBreak{"", TieSplit, TieRunoff, TieRandom} {
+		if err := tb.valid(); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", tb, err)
+		}
+	}
+	if err := TieBreak("coin-toss").valid(); err == nil {
+		t.Error("Expected an unknown tie break to be rejected")
+	}
+}